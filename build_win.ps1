@@ -1 +1 @@
-go build library.go
+go build .
\ No newline at end of file
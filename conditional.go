@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etag is strong entity tag of resource made of versions of its rows, e.g. "3".
+func etag(versions ...int) string {
+	parts := make([]string, len(versions))
+	for i, version := range versions {
+		parts[i] = strconv.Itoa(version)
+	}
+	return `"` + strings.Join(parts, ".") + `"`
+}
+
+// loanETag changes also with book and client of the loan, they are part of its
+// representation, and with days overdue, which grow without changes of the row.
+func loanETag(loan LibraryJoin) string {
+	if loan.Library.Overdue {
+		return etag(loan.Library.Version, loan.Book.Version, loan.Client.Version, loan.Library.DaysOverdue)
+	}
+	return etag(loan.Library.Version, loan.Book.Version, loan.Client.Version)
+}
+
+func loanModified(loan LibraryJoin) time.Time {
+	modified := loan.Library.UpdatedAt
+	times := []time.Time{loan.Book.UpdatedAt, loan.Client.UpdatedAt}
+	if due, err := parseDate(loan.Library.DueDate); err == nil && loan.Library.Overdue {
+		// DaysOverdue was incremented last
+		times = append(times, due.Add(time.Duration(loan.Library.DaysOverdue-1)*day))
+	}
+	for _, t := range times {
+		if t.After(modified) {
+			modified = t
+		}
+	}
+	return modified
+}
+
+// matchesETag tells if header value listing entity tags contains etag or is *.
+// With weak comparison (If-None-Match) W/"3" matches "3", strong comparison
+// (If-Match) skips weak tags.
+func matchesETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets ETag and Last-Modified of GET response. It sends 304 and returns
+// true when client's copy is current by If-None-Match or, without it, If-Modified-Since.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if match := strings.Join(r.Header.Values("If-None-Match"), ","); match != "" {
+		if !matchesETag(match, etag, true) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// preconditionFailed checks If-Match of PUT, PATCH and DELETE against current ETag of
+// resource, so changes of another client are not overwritten. It sends 428 when
+// If-Match is missing and 412 when it does not match, then returns true.
+func preconditionFailed(w http.ResponseWriter, r *http.Request, etag string) bool {
+	match := strings.Join(r.Header.Values("If-Match"), ",")
+	if match == "" {
+		writeProblem(w, r, problemPreconditionRequired, "If-Match header with ETag of "+r.URL.Path+" is required")
+		return true
+	}
+	if !matchesETag(match, etag, false) {
+		writeProblem(w, r, problemPreconditionFailed, r.URL.Path+" was changed, its ETag is "+etag)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, false, true},
+		{`"2", "3"`, false, true},
+		{`*`, false, true},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{`"4"`, true, false},
+		{`"3.1"`, false, false},
+	}
+	for _, tt := range tests {
+		if got := matchesETag(tt.header, `"3"`, tt.weak); got != tt.want {
+			t.Errorf("matchesETag(%s, weak %v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		w := serve(h, "GET", "/api/books/1", "")
+		expect(t, w, http.StatusOK, "")
+		tag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+		if tag != `"1"` || modified == "" {
+			t.Fatalf("ETag = %s, Last-Modified = %s", tag, modified)
+		}
+		expect(t, serve(h, "GET", "/api/books/1", "", "If-None-Match", tag), http.StatusNotModified, "")
+		expect(t, serve(h, "GET", "/api/books/1", "", "If-None-Match", "W/"+tag), http.StatusNotModified, "")
+		expect(t, serve(h, "GET", "/api/books/1", "", "If-Modified-Since", modified), http.StatusNotModified, "")
+		// If-None-Match wins over If-Modified-Since
+		expect(t, serve(h, "GET", "/api/books/1", "", "If-None-Match", `"9"`, "If-Modified-Since", modified), http.StatusOK, "")
+		expect(t, serve(h, "GET", "/api/books/1", "", "If-Modified-Since", time.Unix(0, 0).UTC().Format(http.TimeFormat)), http.StatusOK, "")
+
+		book := `{"Name":"Eden","Author":"Lem"}`
+		expect(t, serve(h, "PUT", "/api/books/1", book), http.StatusPreconditionRequired, problemPreconditionRequired.Code)
+		expect(t, serve(h, "PUT", "/api/books/1", book, "If-Match", "W/"+tag), http.StatusPreconditionFailed, problemPreconditionFailed.Code)
+		w = serve(h, "PUT", "/api/books/1", book, "If-Match", tag)
+		expect(t, w, http.StatusOK, "")
+		if got := w.Header().Get("ETag"); got != `"2"` {
+			t.Errorf("ETag after PUT = %s, want \"2\"", got)
+		}
+		// the second client still has the old version
+		expect(t, serve(h, "PATCH", "/api/books/1", `{"Author":"Stanisław Lem"}`, "Content-Type", mergePatchType, "If-Match", tag), http.StatusPreconditionFailed, problemPreconditionFailed.Code)
+		expect(t, serve(h, "DELETE", "/api/books/1", "", "If-Match", tag), http.StatusPreconditionFailed, problemPreconditionFailed.Code)
+
+		// ETag of loan changes with its book
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/libraries", `{"Library":{"Active":true},"Book":{"Id":1},"Client":{"Id":1}}`), http.StatusCreated, "")
+		w = serve(h, "GET", "/api/libraries/1", "")
+		if got := w.Header().Get("ETag"); got != `"1.2.1"` {
+			t.Errorf("ETag of loan = %s, want \"1.2.1\"", got)
+		}
+		expect(t, serve(h, "PUT", "/api/books/1", book, "If-Match", `"2"`), http.StatusOK, "")
+		expect(t, serve(h, "GET", "/api/libraries/1", "", "If-None-Match", `"1.2.1"`), http.StatusOK, "")
+		expect(t, serve(h, "DELETE", "/api/libraries/1", "", "If-Match", `"1.2.1"`), http.StatusPreconditionFailed, problemPreconditionFailed.Code)
+		expect(t, serve(h, "DELETE", "/api/libraries/1", "", "If-Match", "*"), http.StatusNoContent, "")
+	})
+}
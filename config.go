@@ -0,0 +1,509 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is read from YAML file (library.yaml by default), then overridden by
+// LIBRARY_* environment variables and then by command-line flags.
+type Config struct {
+	Database   DatabaseConfig
+	Server     ServerConfig
+	Log        LogConfig
+	CORS       CORSConfig
+	Pagination PaginationConfig
+	Loans      LoansConfig
+	Holds      HoldsConfig
+	Fines      FinesConfig
+	Tracing    TracingConfig
+}
+
+type DatabaseConfig struct {
+	// mysql, sqlite, postgres or memory
+	Driver string
+	// connection string of the driver, for sqlite path to database file
+	DSN string
+
+	// startup waits until database answers, retrying with doubling interval
+	ConnectTimeout   time.Duration
+	RetryInterval    time.Duration
+	RetryMaxInterval time.Duration
+
+	// connection pool, 0 means no limit
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+type ServerConfig struct {
+	Listen       string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// on SIGINT/SIGTERM in-flight requests have this long to finish
+	ShutdownTimeout time.Duration
+}
+
+type LogConfig struct {
+	// empty logs to stderr
+	File string
+	// debug, info, warn or error
+	Level string
+	// file is rotated when it grows over MaxSize megabytes or every RotateInterval (0 disables),
+	// rotated files are removed after MaxAge days or when there are more than MaxBackups of them
+	MaxSize        int
+	MaxAge         int
+	MaxBackups     int
+	RotateInterval time.Duration
+}
+
+type CORSConfig struct {
+	Origins []string
+}
+
+type PaginationConfig struct {
+	// page size of collections when limit parameter is missing
+	DefaultLimit int
+	// larger limit parameter is rejected
+	MaxLimit int
+}
+
+type TracingConfig struct {
+	// none, stdout or otlp
+	Exporter string
+	// OTLP/HTTP collector URL, e.g. http://localhost:4318, empty uses OTEL_EXPORTER_OTLP_* variables
+	Endpoint string
+	// fraction of traces started here which are recorded, requests with traceparent follow its decision
+	SampleRatio float64
+}
+
+type LoansConfig struct {
+	// time from checkout to due date, renewal moves due date by it
+	Period time.Duration
+	// how many times a loan can be renewed
+	MaxRenewals int
+}
+
+type HoldsConfig struct {
+	// how long returned book waits for the first client in its queue
+	PickupPeriod time.Duration
+}
+
+type FinesConfig struct {
+	// charged for every started day a loan is returned late, 0 disables fines
+	DailyRate decimal.Decimal
+	// fine of one loan is at most this, 0 means no cap
+	MaxFine decimal.Decimal
+}
+
+const defaultConfigFile = "library.yaml"
+
+func defaultConfig() Config {
+	return Config{
+		Database:   DatabaseConfig{Driver: "mysql", ConnectTimeout: 30 * time.Second, RetryInterval: time.Second, RetryMaxInterval: 10 * time.Second, MaxOpenConns: 25, MaxIdleConns: 5, ConnMaxLifetime: 5 * time.Minute},
+		Server:     ServerConfig{Listen: ":10000", ReadTimeout: 15 * time.Second, WriteTimeout: 15 * time.Second, IdleTimeout: 60 * time.Second, ShutdownTimeout: 30 * time.Second},
+		Log:        LogConfig{File: "logs.txt", Level: "info", MaxSize: 100, MaxAge: 30, MaxBackups: 10},
+		CORS:       CORSConfig{Origins: []string{"*"}},
+		Pagination: PaginationConfig{DefaultLimit: 50, MaxLimit: 500},
+		Loans:      LoansConfig{Period: 30 * 24 * time.Hour, MaxRenewals: 2},
+		Holds:      HoldsConfig{PickupPeriod: 72 * time.Hour},
+		Fines:      FinesConfig{DailyRate: decimal.RequireFromString("0.25"), MaxFine: decimal.RequireFromString("10.00")},
+		Tracing:    TracingConfig{Exporter: "none", SampleRatio: 1},
+	}
+}
+
+// setting is a config field which can be overridden by environment variable
+// LIBRARY_<KEY> and flag -<key>, e.g. database.dsn is LIBRARY_DATABASE_DSN and -database-dsn.
+type setting struct {
+	key   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func (s setting) env() string {
+	return "LIBRARY_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.key))
+}
+
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func stringSetting(key, usage string, field func(c *Config) *string) setting {
+	return setting{key, usage, func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func durationSetting(key, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{key, usage, func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration, e.g. 30s", key, value)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+func intSetting(key, usage string, field func(c *Config) *int) setting {
+	return setting{key, usage, func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", key, value)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func floatSetting(key, usage string, field func(c *Config) *float64) setting {
+	return setting{key, usage, func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", key, value)
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+// decimalSetting takes amounts of money, e.g. 0.25.
+func decimalSetting(key, usage string, field func(c *Config) *decimal.Decimal) setting {
+	return setting{key, usage, func(c *Config, value string) error {
+		d, err := decimal.NewFromString(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a decimal number, e.g. 0.25", key, value)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+// listSetting takes comma separated values.
+func listSetting(key, usage string, field func(c *Config) *[]string) setting {
+	return setting{key, usage, func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		*field(c) = list
+		return nil
+	}}
+}
+
+var settings = []setting{
+	stringSetting("database.driver", "storage backend: mysql, sqlite, postgres or memory", func(c *Config) *string { return &c.Database.Driver }),
+	stringSetting("database.dsn", "database connection string, for sqlite path to database file", func(c *Config) *string { return &c.Database.DSN }),
+	durationSetting("database.connect_timeout", "how long to wait for database on start", func(c *Config) *time.Duration { return &c.Database.ConnectTimeout }),
+	durationSetting("database.retry_interval", "first interval between connection attempts on start", func(c *Config) *time.Duration { return &c.Database.RetryInterval }),
+	durationSetting("database.retry_max_interval", "maximum interval between connection attempts on start", func(c *Config) *time.Duration { return &c.Database.RetryMaxInterval }),
+	intSetting("database.max_open_conns", "maximum open connections, 0 is unlimited", func(c *Config) *int { return &c.Database.MaxOpenConns }),
+	intSetting("database.max_idle_conns", "maximum idle connections", func(c *Config) *int { return &c.Database.MaxIdleConns }),
+	durationSetting("database.conn_max_lifetime", "maximum time a connection is reused, 0 is unlimited", func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime }),
+	stringSetting("server.listen", "address to listen on", func(c *Config) *string { return &c.Server.Listen }),
+	durationSetting("server.read_timeout", "maximum time of reading request", func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationSetting("server.write_timeout", "maximum time of writing response", func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	durationSetting("server.idle_timeout", "maximum time to wait for next request on keep-alive connection", func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	durationSetting("server.shutdown_timeout", "how long in-flight requests may finish on shutdown", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+	stringSetting("log.file", "log file path, empty logs to stderr", func(c *Config) *string { return &c.Log.File }),
+	stringSetting("log.level", "minimum level logged: debug, info, warn or error", func(c *Config) *string { return &c.Log.Level }),
+	intSetting("log.max_size", "megabytes after which log file is rotated", func(c *Config) *int { return &c.Log.MaxSize }),
+	intSetting("log.max_age", "days after which rotated log files are removed, 0 keeps them", func(c *Config) *int { return &c.Log.MaxAge }),
+	intSetting("log.max_backups", "number of rotated log files kept, 0 keeps all", func(c *Config) *int { return &c.Log.MaxBackups }),
+	durationSetting("log.rotate_interval", "rotate log file this often regardless of size, 0 disables", func(c *Config) *time.Duration { return &c.Log.RotateInterval }),
+	intSetting("pagination.default_limit", "page size of collections without limit parameter", func(c *Config) *int { return &c.Pagination.DefaultLimit }),
+	intSetting("pagination.max_limit", "maximum page size clients may ask for", func(c *Config) *int { return &c.Pagination.MaxLimit }),
+	durationSetting("loans.period", "time from checkout to due date, e.g. 720h", func(c *Config) *time.Duration { return &c.Loans.Period }),
+	intSetting("loans.max_renewals", "how many times a loan can be renewed, 0 disables renewals", func(c *Config) *int { return &c.Loans.MaxRenewals }),
+	decimalSetting("fines.daily_rate", "fine for every started day a loan is returned late, 0 disables fines", func(c *Config) *decimal.Decimal { return &c.Fines.DailyRate }),
+	decimalSetting("fines.max_fine", "maximum fine of one loan, 0 means no cap", func(c *Config) *decimal.Decimal { return &c.Fines.MaxFine }),
+	durationSetting("holds.pickup_period", "how long returned book is kept for the first client in its queue, e.g. 72h", func(c *Config) *time.Duration { return &c.Holds.PickupPeriod }),
+	stringSetting("tracing.exporter", "where spans are sent: none, stdout or otlp", func(c *Config) *string { return &c.Tracing.Exporter }),
+	stringSetting("tracing.endpoint", "OTLP/HTTP collector URL, e.g. http://localhost:4318", func(c *Config) *string { return &c.Tracing.Endpoint }),
+	floatSetting("tracing.sample_ratio", "fraction of new traces recorded, 0 to 1", func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+	listSetting("cors.origins", "comma separated origins allowed by CORS", func(c *Config) *[]string { return &c.CORS.Origins }),
+}
+
+// loadConfig builds config from file, environment and flags in args. Returns
+// arguments left after flags, e.g. subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	config := defaultConfig()
+
+	flags := flag.NewFlagSet("library", flag.ContinueOnError)
+	configFile := flags.String("config", "", "config file (default "+defaultConfigFile+" if present)")
+	var flagValues []func() error
+	for _, s := range settings {
+		s := s
+		flags.Func(s.flag(), s.usage+" (env "+s.env()+")", func(value string) error {
+			flagValues = append(flagValues, func() error { return s.set(&config, value) })
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return config, nil, err
+	}
+
+	// file
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("LIBRARY_CONFIG")
+	}
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			path = defaultConfigFile
+		}
+	}
+	if path != "" {
+		if err := readConfigFile(path, &config); err != nil {
+			return config, nil, err
+		}
+	}
+
+	// environment
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env()); ok {
+			if err := s.set(&config, value); err != nil {
+				return config, nil, fmt.Errorf("%s: %w", s.env(), err)
+			}
+		}
+	}
+
+	// flags
+	for _, set := range flagValues {
+		if err := set(); err != nil {
+			return config, nil, err
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		return config, nil, err
+	}
+	return config, flags.Args(), nil
+}
+
+// readConfigFile sets values from YAML file, keys are nested like settings keys.
+func readConfigFile(path string, config *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var document yaml.Node
+	if err := yaml.NewDecoder(file).Decode(&document); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	values := make(map[string]string)
+	if err := flattenYAML(document.Content[0], "", values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, s := range settings {
+		if value, ok := values[s.key]; ok {
+			if err := s.set(config, value); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			delete(values, s.key)
+		}
+	}
+	for key := range values {
+		return fmt.Errorf("%s: %s: unknown setting", path, key)
+	}
+	return nil
+}
+
+// flattenYAML collects scalars of mapping node under dotted keys, lists are
+// joined with commas like in environment variables.
+func flattenYAML(node *yaml.Node, prefix string, values map[string]string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := flattenYAML(node.Content[i+1], key, values); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		var items []string
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("%s: line %d: expected list of values", prefix, item.Line)
+			}
+			items = append(items, item.Value)
+		}
+		values[prefix] = strings.Join(items, ",")
+	case yaml.ScalarNode:
+		if prefix == "" {
+			return fmt.Errorf("line %d: expected mapping of settings", node.Line)
+		}
+		values[prefix] = node.Value
+	default:
+		return fmt.Errorf("%s: line %d: unsupported value", prefix, node.Line)
+	}
+	return nil
+}
+
+// validate checks config and normalizes database DSN.
+func (c *Config) validate() error {
+	switch c.Database.Driver {
+	case "mysql":
+		if c.Database.DSN == "" {
+			return fmt.Errorf("database.dsn: required for %s", c.Database.Driver)
+		}
+		// handlers read dates as strings, they need parseTime
+		mysqlConfig, err := mysql.ParseDSN(c.Database.DSN)
+		if err != nil {
+			return fmt.Errorf("database.dsn: %w", err)
+		}
+		mysqlConfig.ParseTime = true
+		// UPDATE reports matched rows, otherwise update with unchanged values looks like missing row
+		mysqlConfig.ClientFoundRows = true
+		c.Database.DSN = mysqlConfig.FormatDSN()
+	case "sqlite", "postgres":
+		if c.Database.DSN == "" {
+			return fmt.Errorf("database.dsn: required for %s", c.Database.Driver)
+		}
+	case "memory":
+	default:
+		return fmt.Errorf("database.driver: unknown storage backend %q, use mysql, sqlite, postgres or memory", c.Database.Driver)
+	}
+
+	if c.Database.RetryInterval <= 0 {
+		return fmt.Errorf("database.retry_interval: must be positive")
+	}
+	if c.Database.RetryMaxInterval < c.Database.RetryInterval {
+		return fmt.Errorf("database.retry_max_interval: must not be shorter than database.retry_interval")
+	}
+	if err := notNegative("database.connect_timeout", c.Database.ConnectTimeout); err != nil {
+		return err
+	}
+	if c.Database.MaxOpenConns < 0 {
+		return fmt.Errorf("database.max_open_conns: must not be negative")
+	}
+	if c.Database.MaxIdleConns < 0 {
+		return fmt.Errorf("database.max_idle_conns: must not be negative")
+	}
+	if err := notNegative("database.conn_max_lifetime", c.Database.ConnMaxLifetime); err != nil {
+		return err
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Listen); err != nil {
+		return fmt.Errorf("server.listen: %q is not host:port, e.g. :10000", c.Server.Listen)
+	}
+	if err := notNegative("server.read_timeout", c.Server.ReadTimeout); err != nil {
+		return err
+	}
+	if err := notNegative("server.write_timeout", c.Server.WriteTimeout); err != nil {
+		return err
+	}
+	if err := notNegative("server.idle_timeout", c.Server.IdleTimeout); err != nil {
+		return err
+	}
+	if err := notNegative("server.shutdown_timeout", c.Server.ShutdownTimeout); err != nil {
+		return err
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return fmt.Errorf("log.level: %q is not debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.MaxSize < 1 {
+		return fmt.Errorf("log.max_size: must be at least 1")
+	}
+	if c.Log.MaxAge < 0 {
+		return fmt.Errorf("log.max_age: must not be negative")
+	}
+	if c.Log.MaxBackups < 0 {
+		return fmt.Errorf("log.max_backups: must not be negative")
+	}
+	if err := notNegative("log.rotate_interval", c.Log.RotateInterval); err != nil {
+		return err
+	}
+
+	if len(c.CORS.Origins) == 0 {
+		return fmt.Errorf("cors.origins: at least one origin is required, use * to allow all")
+	}
+	for _, origin := range c.CORS.Origins {
+		if origin == "" {
+			return fmt.Errorf("cors.origins: empty origin")
+		}
+	}
+
+	if c.Pagination.MaxLimit < 1 {
+		return fmt.Errorf("pagination.max_limit: must be at least 1")
+	}
+	if c.Pagination.DefaultLimit < 1 || c.Pagination.DefaultLimit > c.Pagination.MaxLimit {
+		return fmt.Errorf("pagination.default_limit: must be between 1 and pagination.max_limit")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint != "" {
+			if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("tracing.endpoint: %q is not URL, e.g. http://localhost:4318", c.Tracing.Endpoint)
+			}
+		}
+	default:
+		return fmt.Errorf("tracing.exporter: unknown exporter %q, use none, stdout or otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio: must be between 0 and 1")
+	}
+
+	if c.Loans.Period <= 0 {
+		return fmt.Errorf("loans.period: must be positive")
+	}
+	if c.Loans.MaxRenewals < 0 {
+		return fmt.Errorf("loans.max_renewals: must not be negative")
+	}
+	if c.Holds.PickupPeriod <= 0 {
+		return fmt.Errorf("holds.pickup_period: must be positive")
+	}
+	if err := validAmount("fines.daily_rate", c.Fines.DailyRate); err != nil {
+		return err
+	}
+	if err := validAmount("fines.max_fine", c.Fines.MaxFine); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validAmount accepts amounts the ledger can store, not negative with at most 2 decimal places.
+func validAmount(key string, amount decimal.Decimal) error {
+	if amount.IsNegative() {
+		return fmt.Errorf("%s: must not be negative", key)
+	}
+	if !amount.Equal(amount.Round(2)) {
+		return fmt.Errorf("%s: must have at most 2 decimal places", key)
+	}
+	return nil
+}
+
+func notNegative(key string, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("%s: must not be negative", key)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes YAML config file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "library.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  driver: sqlite
+  dsn: file.db
+server:
+  listen: ":8080"
+  read_timeout: 5s
+cors:
+  origins:
+    - https://a.example
+    - https://b.example
+`)
+	t.Setenv("LIBRARY_DATABASE_DSN", "env.db")
+	t.Setenv("LIBRARY_SERVER_LISTEN", ":9090")
+
+	config, args, err := loadConfig([]string{"-config", path, "-server-listen", ":7070", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaultConfig()
+	want.Database.Driver = "sqlite"
+	want.Database.DSN = "env.db"
+	want.Server.Listen = ":7070"
+	want.Server.ReadTimeout = 5 * time.Second
+	want.CORS.Origins = []string{"https://a.example", "https://b.example"}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+	if !reflect.DeepEqual(args, []string{"migrate", "up"}) {
+		t.Errorf("args = %q, want [migrate up]", args)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		args []string
+		want string
+	}{
+		{"database:\n  user: root\n", nil, "database.user: unknown setting"},
+		{"server:\n  read_timeout: 5\n", nil, "server.read_timeout"},
+		{"database:\n  driver: oracle\n", nil, "database.driver"},
+		{"database:\n  driver: sqlite\n", nil, "database.dsn: required"},
+		{"database:\n  driver: memory\n", []string{"-server-listen", "10000"}, "server.listen"},
+		{"database:\n  driver: memory\n", []string{"-server-idle-timeout", "-1s"}, "server.idle_timeout: must not be negative"},
+		{"database:\n  driver: memory\ncors:\n  origins: []\n", nil, "cors.origins"},
+		{"database:\n  driver: memory\nloans:\n  max_renewals: -1\n", nil, "loans.max_renewals: must not be negative"},
+		{"database:\n  driver: memory\nholds:\n  pickup_period: 0s\n", nil, "holds.pickup_period: must be positive"},
+		{"database:\n  driver: memory\n", []string{"-fines-daily-rate", "0.255"}, "fines.daily_rate: must have at most 2 decimal places"},
+		{"database:\n  driver: memory\nfines:\n  max_fine: ten\n", nil, "fines.max_fine"},
+	}
+	for _, test := range tests {
+		args := append([]string{"-config", writeConfig(t, test.file)}, test.args...)
+		if _, _, err := loadConfig(args); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("loadConfig(%q, %q) = %v, want error %q", test.file, test.args, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxFacetValues limits values of facets ordered by count, e.g. authors.
+const maxFacetValues = 20
+
+// Facet is field of bookFields whose values are counted. With Limit the most
+// common values come first, otherwise all values ordered by value.
+type Facet struct {
+	Name  string
+	Limit int
+}
+
+type FacetCount struct {
+	Value any
+	// number of books having the value
+	Count int
+}
+
+// BooksResponse is body of GET /api/books, Facets are counted when requested
+// with facets parameter.
+type BooksResponse struct {
+	PageResponse[Book]
+	// facet name -> counts of values among books matching filters
+	Facets map[string][]FacetCount `json:",omitempty"`
+}
+
+// bookFacets are values of facets parameter of /api/books.
+var bookFacets = []Facet{
+	{Name: "author", Limit: maxFacetValues},
+	{Name: "available"},
+	{Name: "letter"},
+	{Name: "lent"},
+}
+
+// parseFacets reads facets=author,available. Repeated names are counted once.
+func parseFacets(value string) ([]Facet, []FieldError) {
+	var facets []Facet
+	var errs []FieldError
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(bookFacets, func(facet Facet) bool { return facet.Name == name })
+		if i < 0 {
+			var names []string
+			for _, facet := range bookFacets {
+				names = append(names, facet.Name)
+			}
+			errs = append(errs, FieldError{Field: "facets", Code: fieldInvalid, Message: "facet " + strconv.Quote(name) + " is not supported, facets are " + strings.Join(names, ", ")})
+			continue
+		}
+		if !slices.Contains(facets, bookFacets[i]) {
+			facets = append(facets, bookFacets[i])
+		}
+	}
+	return facets, errs
+}
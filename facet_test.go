@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseFacets(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []Facet
+		errs  int
+	}{
+		{"author", []Facet{{Name: "author", Limit: maxFacetValues}}, 0},
+		{"letter, lent,letter", []Facet{{Name: "letter"}, {Name: "lent"}}, 0},
+		{"available,isbn,", []Facet{{Name: "available"}}, 2},
+	}
+	for _, tt := range tests {
+		got, errs := parseFacets(tt.value)
+		if !reflect.DeepEqual(got, tt.want) || len(errs) != tt.errs {
+			t.Errorf("parseFacets(%q) = %v, %v, want %v and %d errors", tt.value, got, errs, tt.want, tt.errs)
+		}
+	}
+}
+
+func TestFacets(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		for _, book := range []string{
+			`{"Name":"Solaris","Author":"Lem"}`,
+			`{"Name":"Eden","Author":"Lem"}`,
+			`{"Name":"Ubik","Author":"Dick"}`,
+		} {
+			expect(t, serve(h, "POST", "/api/books", book), http.StatusCreated, "")
+		}
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		// Solaris is borrowed, Eden was returned
+		expect(t, serve(h, "POST", "/api/libraries", `{"Library":{"Active":true},"Book":{"Id":1},"Client":{"Id":1}}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/libraries", `{"Library":{"Active":true},"Book":{"Id":2},"Client":{"Id":1}}`), http.StatusCreated, "")
+		expect(t, serve(h, "PUT", "/api/libraries/2", `{"Library":{"Date":"2023-01-02T10:00:00Z","Active":false},"Book":{"Id":2},"Client":{"Id":1}}`, "If-Match", `"1.1.1"`), http.StatusOK, "")
+
+		w := serve(h, "GET", "/api/books?facets=author,available,letter,lent", "")
+		expect(t, w, http.StatusOK, "")
+		want := map[string][]FacetCount{
+			"author":    {{"Lem", 2}, {"Dick", 1}},
+			"available": {{false, 1}, {true, 2}},
+			"letter":    {{"E", 1}, {"S", 1}, {"U", 1}},
+			"lent":      {{false, 1}, {true, 2}},
+		}
+		if got := decodeBody[BooksResponse](t, w); !reflect.DeepEqual(got.Facets, want) {
+			t.Errorf("facets = %v, want %v", got.Facets, want)
+		}
+
+		// counts are of books matching filters
+		w = serve(h, "GET", "/api/books?author=Lem&facets=letter,available", "")
+		want = map[string][]FacetCount{
+			"available": {{false, 1}, {true, 1}},
+			"letter":    {{"E", 1}, {"S", 1}},
+		}
+		if got := decodeBody[BooksResponse](t, w); !reflect.DeepEqual(got.Facets, want) {
+			t.Errorf("facets of Lem = %v, want %v", got.Facets, want)
+		}
+
+		w = serve(h, "GET", "/api/books?available=true&sort=-letter", "")
+		if got := decodeBody[BooksResponse](t, w); got.Total != 2 || got.Items[0].Name != "Ubik" || got.Items[1].Name != "Eden" || got.Facets != nil {
+			t.Errorf("available books = %+v", got)
+		}
+		expect(t, serve(h, "GET", "/api/books?facets=isbn", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+	})
+}
+
+func TestLetterFacet(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		for _, name := range []string{"łąka", "Łódź", "Ślad", "Solaris"} {
+			expect(t, serve(h, "POST", "/api/books", `{"Name":"`+name+`","Author":"Lem"}`), http.StatusCreated, "")
+		}
+		w := serve(h, "GET", "/api/books?facets=letter", "")
+		expect(t, w, http.StatusOK, "")
+		want := []FacetCount{{"S", 1}, {"Ł", 2}, {"Ś", 1}}
+		if got := decodeBody[BooksResponse](t, w).Facets["letter"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("letter facet = %v, want %v", got, want)
+		}
+
+		// filter and sort agree with the facet
+		if got := walkPages(t, h, "/api/books?letter=Ł&limit=1"); !reflect.DeepEqual(got, []string{"łąka", "Łódź"}) {
+			t.Errorf("books of letter Ł = %v", got)
+		}
+		if got := walkPages(t, h, "/api/books?letter=S&limit=1"); !reflect.DeepEqual(got, []string{"Solaris"}) {
+			t.Errorf("books of letter S = %v", got)
+		}
+		if got := walkPages(t, h, "/api/books?sort=-letter&limit=1"); !reflect.DeepEqual(got, []string{"Ślad", "łąka", "Łódź", "Solaris"}) {
+			t.Errorf("books by letter = %v", got)
+		}
+
+		// letter follows name
+		expect(t, serve(h, "PATCH", "/api/books/1", `{"Name":"Akacja"}`, "Content-Type", mergePatchType, "If-Match", `"1"`), http.StatusOK, "")
+		if got := walkPages(t, h, "/api/books?letter=Ł&limit=5"); !reflect.DeepEqual(got, []string{"Łódź"}) {
+			t.Errorf("books of letter Ł after PATCH = %v", got)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldKind tells how values of field are parsed from query parameters and cursors.
+type fieldKind int
+
+const (
+	kindInt fieldKind = iota
+	kindString
+	kindBool
+	kindDate
+)
+
+// listField is field of collection which clients may filter and sort by. Only
+// columns of these whitelists get into SQL, values are always passed as parameters.
+type listField[T any] struct {
+	Name   string
+	Column string
+	Kind   fieldKind
+	// Value reads the field of item, dates as time.Time. It is nil for fields
+	// computed by store from other tables, they can be filtered but not sorted by.
+	Value func(item T) any
+}
+
+// Columns of books computed from table library, active loan means the book is not available.
+const (
+	availableColumn = "NOT EXISTS (SELECT 1 FROM library WHERE library.id_book = book.id AND library.active = TRUE)"
+	lentColumn      = "EXISTS (SELECT 1 FROM library WHERE library.id_book = book.id)"
+)
+
+var bookFields = []listField[Book]{
+	{"id", "id", kindInt, func(book Book) any { return book.Id }},
+	{"name", "name", kindString, func(book Book) any { return book.Name }},
+	{"author", "author", kindString, func(book Book) any { return book.Author }},
+	{"letter", "letter", kindString, func(book Book) any { return firstLetter(book.Name) }},
+	{"available", availableColumn, kindBool, nil},
+	{"lent", lentColumn, kindBool, nil},
+}
+
+// firstLetter is upper case first character of text, empty for empty text. SQL
+// stores keep it of book name in column letter (migration 0009_letters).
+func firstLetter(text string) string {
+	for _, r := range text {
+		return strings.ToUpper(string(r))
+	}
+	return ""
+}
+
+var clientFields = []listField[Client]{
+	{"id", "id", kindInt, func(client Client) any { return client.Id }},
+	{"name", "name", kindString, func(client Client) any { return client.Name }},
+}
+
+var loanFields = []listField[LibraryJoin]{
+	{"id", "library.id", kindInt, func(loan LibraryJoin) any { return loan.Library.Id }},
+	{"book_id", "library.id_book", kindInt, func(loan LibraryJoin) any { return loan.Book.Id }},
+	{"client_id", "library.id_client", kindInt, func(loan LibraryJoin) any { return loan.Client.Id }},
+	{"date", "library.date", kindDate, func(loan LibraryJoin) any { date, _ := parseDate(loan.Library.Date); return date }},
+	{"due_date", "library.due_date", kindDate, func(loan LibraryJoin) any { date, _ := parseDate(loan.Library.DueDate); return date }},
+	{"active", "library.active", kindBool, func(loan LibraryJoin) any { return loan.Library.Active }},
+}
+
+// filter operators
+const (
+	opEqual    = "="
+	opContains = "~"
+	opFrom     = ">="
+	opTo       = "<="
+	opBefore   = "<"
+)
+
+func findField[T any](fields []listField[T], name string) (listField[T], bool) {
+	for _, field := range fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return listField[T]{}, false
+}
+
+// dateLayouts are accepted in filters, the last one is a whole day.
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// parseDate reads dates sent by clients and returned by database drivers.
+func parseDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range dateLayouts {
+		var date time.Time
+		if date, err = time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseValue converts query parameter or cursor value to type of field kind.
+func parseValue(kind fieldKind, value string) (any, bool) {
+	switch kind {
+	case kindInt:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	case kindBool:
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	case kindDate:
+		date, err := parseDate(value)
+		return date, err == nil
+	default:
+		return value, true
+	}
+}
+
+var kindMessages = map[fieldKind]string{
+	kindInt:  "must be a number",
+	kindBool: "must be true or false",
+	kindDate: "must be a date like 2023-01-02 or 2023-01-02T15:04:05Z",
+}
+
+// parseFilters reads parameters like author=Lem, name~=solaris (contains),
+// date_from=2023-01-01 and date_to=2023-01-31. Unknown parameters are rejected,
+// except reserved ones read by the handler.
+func parseFilters[T any](query url.Values, fields []listField[T], reserved ...string) ([]Filter, []FieldError) {
+	var filters []Filter
+	var errs []FieldError
+	params := make([]string, 0, len(query))
+	for param := range query {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		values := query[param]
+		switch param {
+		case "limit", "cursor", "offset", "sort":
+			continue
+		}
+		if slices.Contains(reserved, param) {
+			continue
+		}
+		field, op, ok := filterField(fields, param)
+		if !ok {
+			errs = append(errs, FieldError{Field: param, Code: fieldUnknown, Message: param + " is not a parameter of this collection"})
+			continue
+		}
+		for _, value := range values {
+			parsed, ok := parseValue(field.Kind, value)
+			if !ok {
+				errs = append(errs, FieldError{Field: param, Code: fieldInvalid, Message: param + " " + kindMessages[field.Kind]})
+				continue
+			}
+			filterOp := op
+			if op == opTo && len(value) == len("2006-01-02") {
+				// whole day is included
+				parsed, filterOp = parsed.(time.Time).AddDate(0, 0, 1), opBefore
+			}
+			filters = append(filters, Filter{Field: field.Name, Op: filterOp, Value: parsed})
+		}
+	}
+	return filters, errs
+}
+
+// filterField maps query parameter to field and operator.
+func filterField[T any](fields []listField[T], param string) (listField[T], string, bool) {
+	if name, ok := strings.CutSuffix(param, "~"); ok {
+		field, ok := findField(fields, name)
+		return field, opContains, ok && field.Kind == kindString
+	}
+	if name, ok := strings.CutSuffix(param, "_from"); ok {
+		field, ok := findField(fields, name)
+		return field, opFrom, ok && field.Kind == kindDate
+	}
+	if name, ok := strings.CutSuffix(param, "_to"); ok {
+		field, ok := findField(fields, name)
+		return field, opTo, ok && field.Kind == kindDate
+	}
+	field, ok := findField(fields, param)
+	return field, opEqual, ok && field.Kind != kindDate
+}
+
+// parseSort reads sort=name,-author (minus is descending). Order always ends
+// with id, so it is unique and pages do not skip or repeat items.
+func parseSort[T any](query url.Values, fields []listField[T]) ([]Sort, []FieldError) {
+	var order []Sort
+	var errs []FieldError
+	seen := make(map[string]bool)
+	if value := query.Get("sort"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			name, desc := strings.CutPrefix(strings.TrimSpace(name), "-")
+			if field, ok := findField(fields, name); !ok || field.Value == nil || seen[name] {
+				errs = append(errs, FieldError{Field: "sort", Code: fieldInvalid, Message: "sort by " + strconv.Quote(name) + " is not supported or repeated"})
+				continue
+			}
+			seen[name] = true
+			order = append(order, Sort{Field: name, Desc: desc})
+		}
+	}
+	if !seen["id"] {
+		order = append(order, Sort{Field: "id"})
+	}
+	return order, errs
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// filterTest is query string, filters parsed from it or codes of every error,
+// filters are ignored when there are errors.
+type filterTest struct {
+	query string
+	want  []Filter
+	errs  []string
+}
+
+func testFilters[T any](t *testing.T, fields []listField[T], tests []filterTest, reserved ...string) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, errs := parseFilters(query, fields, reserved...)
+			var codes []string
+			for _, err := range errs {
+				codes = append(codes, err.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.errs) {
+				t.Fatalf("parseFilters(%s) errors = %v, want codes %v", tt.query, errs, tt.errs)
+			}
+			if tt.errs == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFilters(%s) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	testFilters(t, bookFields, []filterTest{
+		{"author=Lem", []Filter{{Field: "author", Op: opEqual, Value: "Lem"}}, nil},
+		{"name~=sol", []Filter{{Field: "name", Op: opContains, Value: "sol"}}, nil},
+		{"name=Solaris&id=7", []Filter{{Field: "id", Op: opEqual, Value: 7}, {Field: "name", Op: opEqual, Value: "Solaris"}}, nil},
+		{"author=Lem&author=Dick", []Filter{{Field: "author", Op: opEqual, Value: "Lem"}, {Field: "author", Op: opEqual, Value: "Dick"}}, nil},
+		{"id=7&available=true", []Filter{{Field: "available", Op: opEqual, Value: true}, {Field: "id", Op: opEqual, Value: 7}}, nil},
+		{"letter=S&lent=false", []Filter{{Field: "lent", Op: opEqual, Value: false}, {Field: "letter", Op: opEqual, Value: "S"}}, nil},
+		{"facets=author&limit=5&cursor=x&offset=1&sort=name", nil, nil},
+
+		{"isbn=123", nil, []string{fieldUnknown}},
+		{"author%3E%3D=Lem", nil, []string{fieldUnknown}},
+		{"id~=1", nil, []string{fieldUnknown}},
+		{"name_from=a", nil, []string{fieldUnknown}},
+		{"id=abc", nil, []string{fieldInvalid}},
+		{"id=1.5", nil, []string{fieldInvalid}},
+		{"id=99999999999999999999", nil, []string{fieldInvalid}},
+		{"available=maybe", nil, []string{fieldInvalid}},
+		{"id=1&id=x&isbn=1", nil, []string{fieldInvalid, fieldUnknown}},
+	}, "facets")
+}
+
+func TestParseDateFilters(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	testFilters(t, loanFields, []filterTest{
+		{"date_from=2023-01-01", []Filter{{Field: "date", Op: opFrom, Value: day(1)}}, nil},
+		// whole day is included
+		{"date_to=2023-01-31", []Filter{{Field: "date", Op: opBefore, Value: day(32)}}, nil},
+		{"date_to=2023-01-02T15:04:05Z", []Filter{{Field: "date", Op: opTo, Value: day(2).Add(15*time.Hour + 4*time.Minute + 5*time.Second)}}, nil},
+		{"due_date_from=2023-01-02&due_date_to=2023-01-02", []Filter{{Field: "due_date", Op: opFrom, Value: day(2)}, {Field: "due_date", Op: opBefore, Value: day(3)}}, nil},
+		{"active=false&client_id=3", []Filter{{Field: "active", Op: opEqual, Value: false}, {Field: "client_id", Op: opEqual, Value: 3}}, nil},
+
+		{"date=2023-01-01", nil, []string{fieldUnknown}},
+		{"active_from=2023-01-01", nil, []string{fieldUnknown}},
+		{"date_from=yesterday", nil, []string{fieldInvalid}},
+		{"date_to=2023-13-01", nil, []string{fieldInvalid}},
+		{"date_to=2023-1-2", nil, []string{fieldInvalid}},
+		{"due_date=2023-01-01", nil, []string{fieldUnknown}},
+		{"active=maybe", nil, []string{fieldInvalid}},
+	})
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    []Sort
+		invalid bool
+	}{
+		{"", []Sort{{Field: "id"}}, false},
+		{"name,-author", []Sort{{Field: "name"}, {Field: "author", Desc: true}, {Field: "id"}}, false},
+		{" letter , name ", []Sort{{Field: "letter"}, {Field: "name"}, {Field: "id"}}, false},
+		{"-id", []Sort{{Field: "id", Desc: true}}, false},
+		{"-id,name", []Sort{{Field: "id", Desc: true}, {Field: "name"}}, false},
+
+		{"isbn", nil, true},
+		// computed from loans, filters only
+		{"available", nil, true},
+		{"name,name", nil, true},
+		{"name,-name", nil, true},
+		{",", nil, true},
+		{"--name", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, errs := parseSort(url.Values{"sort": {tt.sort}}, bookFields)
+			if tt.invalid {
+				if len(errs) == 0 {
+					t.Errorf("parseSort(%q) = %v, want error", tt.sort, got)
+				}
+				return
+			}
+			if len(errs) > 0 || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSort(%q) = %v, %v, want %v", tt.sort, got, errs, tt.want)
+			}
+		})
+	}
+}
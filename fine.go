@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of ledger entries.
+const (
+	ledgerCharge  = "charge"
+	ledgerPayment = "payment"
+	ledgerWaiver  = "waiver"
+)
+
+// maxAmount is the first amount not fitting decimal(10,2) column of table ledger.
+var maxAmount = decimal.New(1, 8)
+
+// overdueFine returns fine of loan due at due and returned at returned and days
+// it is late: daily rate for every started day after due date, at most max fine.
+func (f FinesConfig) overdueFine(due, returned time.Time) (decimal.Decimal, int) {
+	if !returned.After(due) || !f.DailyRate.IsPositive() {
+		return decimal.Zero, 0
+	}
+	days := int((returned.Sub(due) + day - 1) / day)
+	fine := f.DailyRate.Mul(decimal.NewFromInt(int64(days)))
+	if f.MaxFine.IsPositive() && fine.GreaterThan(f.MaxFine) {
+		fine = f.MaxFine
+	}
+	return fine, days
+}
+
+// overdueNote describes fine of loan returned days late.
+func overdueNote(days int) string {
+	if days == 1 {
+		return "returned 1 day late"
+	}
+	return "returned " + strconv.Itoa(days) + " days late"
+}
+
+func balanceOf(entries []LedgerEntry) Balance {
+	balance := Balance{Charges: decimal.Zero, Payments: decimal.Zero, Waivers: decimal.Zero}
+	for _, entry := range entries {
+		switch entry.Kind {
+		case ledgerCharge:
+			balance.Charges = balance.Charges.Add(entry.Amount)
+		case ledgerPayment:
+			balance.Payments = balance.Payments.Add(entry.Amount)
+		case ledgerWaiver:
+			balance.Waivers = balance.Waivers.Add(entry.Amount)
+		}
+	}
+	balance.Balance = balance.Charges.Sub(balance.Payments).Sub(balance.Waivers)
+	return balance
+}
+
+func validateLedger(entry LedgerRequest) []FieldError {
+	var errs []FieldError
+	switch {
+	case entry.Amount.IsZero():
+		errs = append(errs, FieldError{Field: "Amount", Code: fieldRequired, Message: "Amount is required"})
+	case entry.Amount.IsNegative():
+		errs = append(errs, FieldError{Field: "Amount", Code: fieldInvalid, Message: "Amount must be positive"})
+	case !entry.Amount.Equal(entry.Amount.Round(2)):
+		errs = append(errs, FieldError{Field: "Amount", Code: fieldInvalid, Message: "Amount must have at most 2 decimal places"})
+	case !entry.Amount.LessThan(maxAmount):
+		errs = append(errs, FieldError{Field: "Amount", Code: fieldInvalid, Message: "Amount must be less than " + maxAmount.String()})
+	}
+	if entry.Loan < 0 {
+		errs = append(errs, FieldError{Field: "Loan", Code: fieldInvalid, Message: "Loan must be positive"})
+	}
+	errs = append(errs, maxLength("Note", entry.Note, 255)...)
+	return errs
+}
+
+// GET /api/clients/1/balance
+func getBalance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+vars_id+"/balance "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	entries, errStore := store.GetLedger(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+vars_id+"/balance "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+vars_id+"/balance "+errStore.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(balanceOf(entries))
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+vars_id+"/balance "+errEncode.Error())
+		return
+	}
+}
+
+// GET /api/clients/1/ledger
+func getLedger(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+vars_id+"/ledger "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	entries, errStore := store.GetLedger(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+vars_id+"/ledger "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+vars_id+"/ledger "+errStore.Error())
+		return
+	}
+	response := LedgerResponse{Items: entries}
+	if response.Items == nil {
+		response.Items = []LedgerEntry{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+vars_id+"/ledger "+errEncode.Error())
+		return
+	}
+}
+
+// POST /api/clients/1/charges LedgerRequest{}
+func postCharge(w http.ResponseWriter, r *http.Request) {
+	postLedgerEntry(w, r, ledgerCharge)
+}
+
+// POST /api/clients/1/payments LedgerRequest{}
+func postPayment(w http.ResponseWriter, r *http.Request) {
+	postLedgerEntry(w, r, ledgerPayment)
+}
+
+// POST /api/clients/1/waivers LedgerRequest{}
+func postWaiver(w http.ResponseWriter, r *http.Request) {
+	postLedgerEntry(w, r, ledgerWaiver)
+}
+
+func postLedgerEntry(w http.ResponseWriter, r *http.Request, kind string) {
+	var payload LedgerRequest
+
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	path := "/api/clients/" + vars_id + "/" + kind + "s"
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "POST "+path+" "+errAtoi.Error())
+		return
+	}
+	requestBody, errIO := ioutil.ReadAll(r.Body)
+	if errIO != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST "+path+" "+errIO.Error())
+		return
+	}
+	errUnmarshal := json.Unmarshal(requestBody, &payload)
+	if errUnmarshal != nil {
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "POST "+path+" "+errUnmarshal.Error())
+		return
+	}
+	// wrong JSON
+	if errs := validateLedger(payload); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "POST "+path+" wrong JSON")
+		return
+	}
+
+	// repository
+	entry, errStore := store.AddLedgerEntry(r.Context(), int_id, LedgerEntry{Kind: kind, Amount: payload.Amount, Loan: payload.Loan, Note: payload.Note})
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "POST "+path+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrUnknownLoan) {
+		writeValidationProblem(w, r, []FieldError{{Field: "Loan", Code: fieldUnknown, Message: "client " + vars_id + " has no loan " + strconv.Itoa(payload.Loan)}})
+		slog.WarnContext(r.Context(), "POST "+path+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrOverBalance) {
+		writeProblem(w, r, problemOverBalance, kind+" of "+payload.Amount.String()+" exceeds balance of client "+vars_id)
+		slog.WarnContext(r.Context(), "POST "+path+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST "+path+" "+errStore.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	errEncode := json.NewEncoder(w).Encode(entry)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "POST "+path+" "+errEncode.Error())
+		return
+	}
+}
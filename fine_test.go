@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestOverdueFine(t *testing.T) {
+	due := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	fines := FinesConfig{DailyRate: decimal.RequireFromString("0.50"), MaxFine: decimal.RequireFromString("5")}
+	tests := []struct {
+		name     string
+		fines    FinesConfig
+		returned time.Time
+		want     string
+		wantDays int
+	}{
+		{"early", fines, due.Add(-day), "0", 0},
+		{"on due date", fines, due, "0", 0},
+		{"second late", fines, due.Add(time.Second), "0.5", 1},
+		{"whole day late", fines, due.Add(day), "0.5", 1},
+		{"day and second late", fines, due.Add(day + time.Second), "1", 2},
+		{"at max fine", fines, due.Add(10 * day), "5", 10},
+		{"over max fine", fines, due.Add(30 * day), "5", 30},
+		{"no cap", FinesConfig{DailyRate: fines.DailyRate}, due.Add(30 * day), "15", 30},
+		{"fines disabled", FinesConfig{MaxFine: fines.MaxFine}, due.Add(30 * day), "0", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fine, days := tt.fines.overdueFine(due, tt.returned)
+			if !fine.Equal(decimal.RequireFromString(tt.want)) || days != tt.wantDays {
+				t.Errorf("overdueFine = %s, %d, want %s, %d", fine, days, tt.want, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestBalanceOf(t *testing.T) {
+	amount := decimal.RequireFromString
+	tests := []struct {
+		name    string
+		entries []LedgerEntry
+		want    [4]string
+	}{
+		{"empty", nil, [4]string{"0", "0", "0", "0"}},
+		{"charges only", []LedgerEntry{{Kind: ledgerCharge, Amount: amount("1.50")}, {Kind: ledgerCharge, Amount: amount("2.25")}}, [4]string{"3.75", "0", "0", "3.75"}},
+		{"paid off", []LedgerEntry{{Kind: ledgerCharge, Amount: amount("3")}, {Kind: ledgerPayment, Amount: amount("2")}, {Kind: ledgerWaiver, Amount: amount("1")}}, [4]string{"3", "2", "1", "0"}},
+		{"partly paid", []LedgerEntry{{Kind: ledgerCharge, Amount: amount("0.10")}, {Kind: ledgerCharge, Amount: amount("0.20")}, {Kind: ledgerPayment, Amount: amount("0.15")}}, [4]string{"0.3", "0.15", "0", "0.15"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := balanceOf(tt.entries)
+			for i, value := range []decimal.Decimal{got.Charges, got.Payments, got.Waivers, got.Balance} {
+				if !value.Equal(amount(tt.want[i])) {
+					t.Errorf("balanceOf = %+v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestValidateLedger(t *testing.T) {
+	amount := decimal.RequireFromString
+	tests := []struct {
+		name  string
+		entry LedgerRequest
+		// fields of errors
+		want []string
+	}{
+		{"valid", LedgerRequest{Amount: amount("12.34"), Loan: 1, Note: "lost book"}, nil},
+		{"largest amount", LedgerRequest{Amount: amount("99999999.99")}, nil},
+		{"missing amount", LedgerRequest{}, []string{"Amount"}},
+		{"negative amount", LedgerRequest{Amount: amount("-1")}, []string{"Amount"}},
+		{"fraction of cent", LedgerRequest{Amount: amount("0.001")}, []string{"Amount"}},
+		{"too large amount", LedgerRequest{Amount: amount("100000000")}, []string{"Amount"}},
+		{"negative loan", LedgerRequest{Amount: amount("1"), Loan: -1}, []string{"Loan"}},
+		{"long note", LedgerRequest{Amount: amount("1"), Note: strings.Repeat("ą", 256)}, []string{"Note"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range validateLedger(tt.entry) {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("validateLedger(%+v) fields = %v, want %v", tt.entry, fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddLedgerEntryOverBalance(t *testing.T) {
+	for _, backend := range testStores {
+		t.Run(backend, func(t *testing.T) {
+			newTestHandler(t, backend)
+			ctx := context.Background()
+			clientId, err := store.CreateClient(ctx, ClientRequest{Name: "Jan"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			amount := decimal.RequireFromString
+			steps := []struct {
+				kind   string
+				amount string
+				want   error
+			}{
+				{ledgerPayment, "0.01", ErrOverBalance},
+				{ledgerCharge, "5", nil},
+				{ledgerPayment, "3", nil},
+				{ledgerWaiver, "2.01", ErrOverBalance},
+				{ledgerWaiver, "2", nil},
+				{ledgerPayment, "0.01", ErrOverBalance},
+				{ledgerCharge, "0.01", nil},
+				{ledgerPayment, "0.01", nil},
+			}
+			for i, step := range steps {
+				_, err := store.AddLedgerEntry(ctx, clientId, LedgerEntry{Kind: step.kind, Amount: amount(step.amount)})
+				if !errors.Is(err, step.want) {
+					t.Fatalf("step %d: %s of %s error = %v, want %v", i, step.kind, step.amount, err, step.want)
+				}
+			}
+			entries, err := store.GetLedger(ctx, clientId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance := balanceOf(entries); !balance.Balance.IsZero() || len(entries) != 5 {
+				t.Errorf("balance = %s of %d entries, want 0 of 5", balance.Balance, len(entries))
+			}
+
+			if _, err := store.AddLedgerEntry(ctx, clientId+1, LedgerEntry{Kind: ledgerCharge, Amount: amount("1")}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("charge of unknown client error = %v, want %v", err, ErrNotFound)
+			}
+			if _, err := store.AddLedgerEntry(ctx, clientId, LedgerEntry{Kind: ledgerCharge, Amount: amount("1"), Loan: 1}); !errors.Is(err, ErrUnknownLoan) {
+				t.Errorf("charge of unknown loan error = %v, want %v", err, ErrUnknownLoan)
+			}
+		})
+	}
+}
+
+func TestLedger(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		// returned 3 started days late
+		loan := LibraryRequestJoin{Library: LibraryRequest{Active: true}, Book: Book{Id: 1}, Client: Client{Id: 1}}
+		if _, err := store.CreateLoan(context.Background(), loan, time.Now().Add(-2*day-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusOK, "")
+
+		expect(t, serve(h, "POST", "/api/clients/1/payments", `{"Amount":"1"}`), http.StatusConflict, problemOverBalance.Code)
+		expect(t, serve(h, "POST", "/api/clients/1/payments", `{"Amount":"0.001"}`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "POST", "/api/clients/1/charges", `{"Amount":"2.50","Loan":9}`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "POST", "/api/clients/1/charges", `{"Amount":"2.50","Note":"damaged cover"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients/1/payments", `{"Amount":3}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients/1/waivers", `{"Amount":"0.25","Loan":1}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients/9/payments", `{"Amount":"1"}`), http.StatusNotFound, problemNotFound.Code)
+
+		w := serve(h, "GET", "/api/clients/1/balance", "")
+		expect(t, w, http.StatusOK, "")
+		if balance := decodeBody[Balance](t, w); balance.Charges.String() != "3.25" || balance.Payments.String() != "3" || balance.Waivers.String() != "0.25" || !balance.Balance.IsZero() {
+			t.Errorf("balance = %+v, want 3.25 charged, 3 paid and 0.25 waived", balance)
+		}
+		w = serve(h, "GET", "/api/clients/1/ledger", "")
+		expect(t, w, http.StatusOK, "")
+		entries := decodeBody[LedgerResponse](t, w).Items
+		if len(entries) != 4 || entries[0].Kind != ledgerCharge || entries[0].Loan != 1 || entries[0].Amount.String() != "0.75" || entries[0].Note != overdueNote(3) {
+			t.Errorf("ledger = %+v, want fine of 0.75 first", entries)
+		}
+		expect(t, serve(h, "GET", "/api/clients/9/ledger", ""), http.StatusNotFound, problemNotFound.Code)
+		expect(t, serve(h, "GET", "/api/clients/9/balance", ""), http.StatusNotFound, problemNotFound.Code)
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status     string
+	Components map[string]ComponentStatus `json:",omitempty"`
+}
+
+type ComponentStatus struct {
+	Status  string
+	Version string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// GET /healthz
+// process is up and serving requests
+func getHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, HealthResponse{Status: "ok"})
+}
+
+// GET /readyz
+// database answers and its schema is up to date, otherwise 503
+func getReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	response := HealthResponse{Status: "ok", Components: make(map[string]ComponentStatus)}
+
+	database := ComponentStatus{Status: "ok"}
+	if errPing := store.Ping(ctx); errPing != nil {
+		database = ComponentStatus{Status: "fail", Error: errPing.Error()}
+	} else if version, errVersion := store.Version(ctx); errVersion != nil {
+		database = ComponentStatus{Status: "fail", Error: errVersion.Error()}
+	} else {
+		database.Version = version
+	}
+	response.Components["database"] = database
+
+	if migrator, ok := store.(Migrator); ok {
+		response.Components["migrations"] = migrationsHealth(ctx, migrator)
+	}
+
+	for _, component := range response.Components {
+		if component.Status != "ok" {
+			response.Status = "fail"
+		}
+	}
+	writeHealth(w, r, response)
+}
+
+func migrationsHealth(ctx context.Context, migrator Migrator) ComponentStatus {
+	statuses, err := migrator.MigrationStatus(ctx)
+	if err != nil {
+		return ComponentStatus{Status: "fail", Error: err.Error()}
+	}
+	var pending, latest int
+	for _, status := range statuses {
+		if status.Applied {
+			latest = status.Version
+		} else {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return ComponentStatus{Status: "fail", Version: fmt.Sprintf("%04d", latest), Error: fmt.Sprintf("%d pending migrations", pending)}
+	}
+	return ComponentStatus{Status: "ok", Version: fmt.Sprintf("%04d", latest)}
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, response HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if response.Status == "ok" {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		slog.WarnContext(r.Context(), r.Method+" "+r.URL.Path+" not ready", "components", response.Components)
+	}
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		slog.ErrorContext(r.Context(), r.Method+" "+r.URL.Path+" "+errEncode.Error())
+	}
+}
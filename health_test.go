@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		w := serve(h, "GET", "/healthz", "")
+		expect(t, w, http.StatusOK, "")
+		if health := decodeBody[HealthResponse](t, w); health.Status != "ok" {
+			t.Errorf("GET /healthz = %+v", health)
+		}
+
+		w = serve(h, "GET", "/readyz", "")
+		expect(t, w, http.StatusOK, "")
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+		}
+		if ready := decodeBody[HealthResponse](t, w); ready.Status != "ok" || ready.Components["database"].Version == "" {
+			t.Errorf("GET /readyz = %+v", ready)
+		}
+	})
+}
+
+func TestReadyPendingMigrations(t *testing.T) {
+	h := newTestHandler(t, "memory")
+	sqlite, err := newSQLiteStore(t.TempDir() + "/library.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	store = sqlite
+
+	w := serve(h, "GET", "/readyz", "")
+	expect(t, w, http.StatusServiceUnavailable, "")
+	if ready := decodeBody[HealthResponse](t, w); ready.Status != "fail" || ready.Components["migrations"].Status != "fail" {
+		t.Errorf("GET /readyz without migrations = %+v", ready)
+	}
+	// probes only read the database
+	var tables int
+	if err := sqlite.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("tables after GET /readyz = %d, %v, want none", tables, err)
+	}
+}
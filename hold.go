@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Statuses of holds. Waiting holds become ready when the book is returned,
+// ready ones are fulfilled by checkout or expire after holds.pickup_period.
+const (
+	holdWaiting   = "waiting"
+	holdReady     = "ready"
+	holdFulfilled = "fulfilled"
+	holdCancelled = "cancelled"
+	holdExpired   = "expired"
+)
+
+// holdsUpdateInterval is how often ready holds not picked up are expired.
+const holdsUpdateInterval = time.Minute
+
+// updateHolds expires holds in background until ctx is done, holds are updated
+// by requests only when their book is returned or a hold is cancelled.
+func updateHolds(ctx context.Context) {
+	ticker := time.NewTicker(holdsUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			errUpdate := store.UpdateHolds(ctx, config.Holds.PickupPeriod)
+			if errUpdate != nil && ctx.Err() == nil {
+				slog.Error("holds " + errUpdate.Error())
+			}
+		}
+	}
+}
+
+// POST /api/books/1/holds HoldRequest{}
+func postHold(w http.ResponseWriter, r *http.Request) {
+	var payload HoldRequest
+
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errAtoi.Error())
+		return
+	}
+	requestBody, errIO := ioutil.ReadAll(r.Body)
+	if errIO != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errIO.Error())
+		return
+	}
+	errUnmarshal := json.Unmarshal(requestBody, &payload)
+	if errUnmarshal != nil {
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errUnmarshal.Error())
+		return
+	}
+	// wrong JSON
+	if errs := requiredId("Client.Id", payload.Client.Id); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds wrong JSON or ID")
+		return
+	}
+
+	// repository
+	hold, errStore := store.PlaceHold(r.Context(), int_id, payload.Client.Id)
+	if errors.Is(errStore, ErrUnknownBook) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrHoldExists) {
+		writeProblem(w, r, problemHoldExists, "client "+strconv.Itoa(payload.Client.Id)+" waits for book "+vars_id+" already")
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrBookOnLoan) {
+		writeProblem(w, r, problemBookOnLoan, "book "+vars_id+" is on loan to client "+strconv.Itoa(payload.Client.Id))
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrBookAvailable) {
+		writeProblem(w, r, problemBookAvailable, "book "+vars_id+" can be checked out")
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if referenceProblem(w, r, errStore, LibraryRequestJoin{Book: Book{Id: int_id}, Client: payload.Client}) {
+		slog.WarnContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	errEncode := json.NewEncoder(w).Encode(hold)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "POST /api/books/"+vars_id+"/holds "+errEncode.Error())
+		return
+	}
+}
+
+// GET /api/books/1/holds
+func getBookHolds(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/books/"+vars_id+"/holds "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	holds, errStore := store.BookHolds(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/books/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	writeHolds(w, r, holds)
+}
+
+// GET /api/clients/1/holds
+func getClientHolds(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+vars_id+"/holds "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	holds, errStore := store.ClientHolds(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+vars_id+"/holds "+errStore.Error())
+		return
+	}
+	writeHolds(w, r, holds)
+}
+
+func writeHolds(w http.ResponseWriter, r *http.Request, holds []Hold) {
+	response := HoldsResponse{Items: holds}
+	if response.Items == nil {
+		response.Items = []Hold{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET "+r.URL.Path+" "+errEncode.Error())
+		return
+	}
+}
+
+// DELETE /api/clients/1/holds/2
+func deleteClientHold(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id, vars_hold := vars["id"], vars["hold"]
+	path := "/api/clients/" + vars_id + "/holds/" + vars_hold
+	// validate if ids == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "DELETE "+path+" "+errAtoi.Error())
+		return
+	}
+	int_hold, errAtoi := strconv.Atoi(vars_hold)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_hold+" is not a number")
+		slog.WarnContext(r.Context(), "DELETE "+path+" "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	errStore := store.CancelHold(r.Context(), int_id, int_hold, config.Holds.PickupPeriod)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" has no hold "+vars_hold)
+		slog.WarnContext(r.Context(), "DELETE "+path+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrHoldClosed) {
+		writeProblem(w, r, problemHoldClosed, "hold "+vars_hold+" is not waiting nor ready")
+		slog.WarnContext(r.Context(), "DELETE "+path+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE "+path+" "+errStore.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
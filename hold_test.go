@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// holdStatuses returns holds listed at path with only client id, status and position.
+func holdStatuses(t *testing.T, h http.Handler, path string) []Hold {
+	t.Helper()
+	w := serve(h, "GET", path, "")
+	expect(t, w, http.StatusOK, "")
+	holds := decodeBody[HoldsResponse](t, w).Items
+	for i := range holds {
+		holds[i] = Hold{Client: Client{Id: holds[i].Client.Id}, Status: holds[i].Status, Position: holds[i].Position}
+	}
+	return holds
+}
+
+// newHoldsFixture creates book 1, clients 1, 2 and 3 and lends the book to client 1.
+func newHoldsFixture(t *testing.T, h http.Handler) {
+	t.Helper()
+	expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+	for _, name := range []string{"Jan", "Anna", "Ewa"} {
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"`+name+`"}`), http.StatusCreated, "")
+	}
+	expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`), http.StatusConflict, problemBookAvailable.Code)
+	expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":1}}`), http.StatusCreated, "")
+}
+
+func TestPlaceHold(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		newHoldsFixture(t, h)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":1}}`), http.StatusConflict, problemBookOnLoan.Code)
+		w := serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`)
+		expect(t, w, http.StatusCreated, "")
+		if hold := decodeBody[Hold](t, w); hold.Status != holdWaiting || hold.Position != 1 || hold.Book.Name != "Solaris" || hold.Client.Name != "Anna" {
+			t.Errorf("placed hold = %+v", hold)
+		}
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`), http.StatusConflict, problemHoldExists.Code)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":3}}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/books/9/holds", `{"Client":{"Id":3}}`), http.StatusNotFound, problemNotFound.Code)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":9}}`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{}`), http.StatusBadRequest, problemValidation.Code)
+
+		want := []Hold{{Client: Client{Id: 2}, Status: holdWaiting, Position: 1}, {Client: Client{Id: 3}, Status: holdWaiting, Position: 2}}
+		if got := holdStatuses(t, h, "/api/books/1/holds"); !slices.Equal(got, want) {
+			t.Errorf("holds of book = %+v, want %+v", got, want)
+		}
+		if got := holdStatuses(t, h, "/api/clients/3/holds"); !slices.Equal(got, want[1:]) {
+			t.Errorf("holds of client 3 = %+v, want %+v", got, want[1:])
+		}
+		expect(t, serve(h, "GET", "/api/books/9/holds", ""), http.StatusNotFound, problemNotFound.Code)
+		expect(t, serve(h, "GET", "/api/clients/9/holds", ""), http.StatusNotFound, problemNotFound.Code)
+		// the first client in queue waits, the book can not be renewed
+		expect(t, serve(h, "POST", "/api/libraries/1/renew", ""), http.StatusConflict, problemBookOnHold.Code)
+	})
+}
+
+func TestHoldsQueue(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		newHoldsFixture(t, h)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":3}}`), http.StatusCreated, "")
+
+		// the first hold is ready when the book is returned
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusOK, "")
+		w := serve(h, "GET", "/api/books/1/holds", "")
+		holds := decodeBody[HoldsResponse](t, w).Items
+		if len(holds) != 2 || holds[0].Client.Id != 2 || holds[0].Status != holdReady || holds[0].ReadyDate == "" || holds[0].ExpiryDate <= holds[0].ReadyDate || holds[1].Status != holdWaiting {
+			t.Fatalf("holds after return = %+v", holds)
+		}
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":1}}`), http.StatusCreated, "")
+
+		// the book is kept for client 2
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":3}}`), http.StatusConflict, problemBookOnHold.Code)
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":1}}`), http.StatusConflict, problemBookOnHold.Code)
+
+		// cancelled ready hold passes the book to client 3
+		expect(t, serve(h, "DELETE", "/api/clients/3/holds/1", ""), http.StatusNotFound, problemNotFound.Code)
+		expect(t, serve(h, "DELETE", "/api/clients/2/holds/1", ""), http.StatusNoContent, "")
+		expect(t, serve(h, "DELETE", "/api/clients/2/holds/1", ""), http.StatusConflict, problemHoldClosed.Code)
+		want := []Hold{{Client: Client{Id: 3}, Status: holdReady, Position: 1}, {Client: Client{Id: 1}, Status: holdWaiting, Position: 2}}
+		if got := holdStatuses(t, h, "/api/books/1/holds"); !slices.Equal(got, want) {
+			t.Errorf("holds after cancel = %+v, want %+v", got, want)
+		}
+
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":3}}`), http.StatusCreated, "")
+		want = []Hold{{Client: Client{Id: 3}, Status: holdFulfilled}}
+		if got := holdStatuses(t, h, "/api/clients/3/holds"); !slices.Equal(got, want) {
+			t.Errorf("holds of client 3 after checkout = %+v, want %+v", got, want)
+		}
+		want = []Hold{{Client: Client{Id: 2}, Status: holdCancelled}}
+		if got := holdStatuses(t, h, "/api/clients/2/holds"); !slices.Equal(got, want) {
+			t.Errorf("holds of client 2 = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestHoldsExpire(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		newHoldsFixture(t, h)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":3}}`), http.StatusCreated, "")
+
+		// ready hold of client 2 expires before it is picked up
+		config.Holds.PickupPeriod = -time.Minute
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusOK, "")
+		if err := store.UpdateHolds(context.Background(), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		want := []Hold{{Client: Client{Id: 3}, Status: holdReady, Position: 1}}
+		if got := holdStatuses(t, h, "/api/books/1/holds"); !slices.Equal(got, want) {
+			t.Errorf("holds after expiry = %+v, want %+v", got, want)
+		}
+		want = []Hold{{Client: Client{Id: 2}, Status: holdExpired}}
+		if got := holdStatuses(t, h, "/api/clients/2/holds"); !slices.Equal(got, want) {
+			t.Errorf("holds of client 2 = %+v, want %+v", got, want)
+		}
+
+		// ready hold of client 3 is kept
+		if err := store.UpdateHolds(context.Background(), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":2}}`), http.StatusConflict, problemBookOnHold.Code)
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":3}}`), http.StatusCreated, "")
+	})
+}
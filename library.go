@@ -1,25 +1,31 @@
 package main
 
 import (
-	"bufio"
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"github.com/shopspring/decimal"
 )
 
 const ()
 
 var (
-	db *sql.DB
+	config Config
+	store  Store
 )
 
 // MODELS --------------------------------------------------------------------------
@@ -28,6 +34,9 @@ type Book struct {
 	Id     int
 	Name   string
 	Author string
+	// sent in ETag and Last-Modified headers
+	Version   int       `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type BookRequest struct {
@@ -42,6 +51,9 @@ type BookResponse struct {
 type Client struct {
 	Id   int
 	Name string
+	// sent in ETag and Last-Modified headers
+	Version   int       `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type ClientRequest struct {
@@ -56,6 +68,17 @@ type Library struct {
 	Id     int
 	Date   string
 	Active bool
+	// set by POST /api/loans/{id}/return
+	ReturnDate string `json:",omitempty"`
+	// checkout time + loans.period, moved by renewals
+	DueDate  string
+	Renewals int
+	// computed when read: active loan past DueDate and days (started) since then
+	Overdue     bool
+	DaysOverdue int `json:",omitempty"`
+	// sent in ETag and Last-Modified headers
+	Version   int       `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type LibraryJoin struct {
@@ -75,81 +98,284 @@ type LibraryRequestJoin struct {
 	Client  Client
 }
 
+// LibraryPatch is document patched by PATCH /api/libraries/1, book and client
+// are changed by id only.
+type LibraryPatch struct {
+	Library LibraryRequest
+	Book    BookResponse
+	Client  ClientResponse
+}
+
+// libraryPatchPaths are JSON Pointers of LibraryPatch fields clients may patch.
+var libraryPatchPaths = []string{"/Library/Date", "/Library/Active", "/Book/Id", "/Client/Id"}
+
 type LibraryResponse struct {
 	Id int
 }
 
-// FUNC -----------------------------------------------------------------------------
+type Renewal struct {
+	Id              int
+	Date            string
+	PreviousDueDate string
+	DueDate         string
+}
 
-func getConfig() {
-	var fileLines []string
-	readFile, _ := os.Open("library.config")
-	fileScanner := bufio.NewScanner(readFile)
-	fileScanner.Split(bufio.ScanLines)
-	for fileScanner.Scan() {
-		fileLines = append(fileLines, fileScanner.Text())
-	}
-	readFile.Close()
+type RenewalsResponse struct {
+	Items []Renewal
+}
+
+type Hold struct {
+	Id     int
+	Book   Book
+	Client Client
+	Date   string
+	// one of hold* constants
+	Status string
+	// place in queue of the book while waiting or ready, 1 is the first
+	Position int `json:",omitempty"`
+	// book is kept for the client from ReadyDate to ExpiryDate
+	ReadyDate  string `json:",omitempty"`
+	ExpiryDate string `json:",omitempty"`
+}
 
-	connectionString := fileLines[0] + ":" + fileLines[1] + "@" + fileLines[2] + "/" + fileLines[3] + "?parseTime=true"
+type HoldRequest struct {
+	Client Client
+}
 
-	// Create the database handle, confirm driver is present
-	db, _ = sql.Open("mysql", connectionString)
+type HoldsResponse struct {
+	Items []Hold
 }
 
-func log2File() {
-	// If the file doesn't exist, create it or append to the file
-	file, err := os.OpenFile("logs.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatal(err)
-	}
+type LedgerEntry struct {
+	Id   int
+	Date string
+	// one of ledger* constants, charges add to balance, payments and waivers subtract
+	Kind   string
+	Amount decimal.Decimal
+	// loan the entry is for, 0 for none
+	Loan int    `json:",omitempty"`
+	Note string `json:",omitempty"`
+}
 
-	log.SetOutput(file)
+// LedgerRequest is body of charge, payment and waiver, Loan is optional.
+type LedgerRequest struct {
+	Amount decimal.Decimal
+	Loan   int
+	Note   string
 }
 
-func handleRequests() { // router
-	router := mux.NewRouter()
+type LedgerResponse struct {
+	Items []LedgerEntry
+}
+
+// Balance sums ledger of client, Balance is what the client owes.
+type Balance struct {
+	Charges  decimal.Decimal
+	Payments decimal.Decimal
+	Waivers  decimal.Decimal
+	Balance  decimal.Decimal
+}
+
+// FUNC -----------------------------------------------------------------------------
 
-	router.HandleFunc("/api/books/{id}", getBook).Methods("GET")       // returns book by id
-	router.HandleFunc("/api/books", getBooks).Methods("GET")           // returns all books
-	router.HandleFunc("/api/books", postBook).Methods("POST")          // creates book, returns id of created book
-	router.HandleFunc("/api/books/{id}", putBook).Methods("PUT")       // updates book by id
-	router.HandleFunc("/api/books/{id}", deleteBook).Methods("DELETE") // deletes book by id
+// getConfig loads config and opens storage, returns arguments left after flags.
+func getConfig() []string {
+	var args []string
+	var err error
+	config, args, err = loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(2)
+	}
 
-	router.HandleFunc("/api/clients/{id}", getClient).Methods("GET")       // returns client by id
-	router.HandleFunc("/api/clients", getClients).Methods("GET")           // returns all clients
-	router.HandleFunc("/api/clients", postClient).Methods("POST")          // creates client, returns id of created client
-	router.HandleFunc("/api/clients/{id}", putClient).Methods("PUT")       // updates client by id
-	router.HandleFunc("/api/clients/{id}", deleteClient).Methods("DELETE") // deletes client by id
+	store, err = openStore(config.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "database:", err)
+		os.Exit(1)
+	}
+	return args
+}
 
-	router.HandleFunc("/api/libraries/{id}", getLibrary).Methods("GET")       // returns borrow by id
-	router.HandleFunc("/api/libraries", getLibraries).Methods("GET")          // returns all borrowed books
-	router.HandleFunc("/api/libraries", postLibrary).Methods("POST")          // creates borrow, returns id of created borrow
-	router.HandleFunc("/api/libraries/{id}", putLibrary).Methods("PUT")       // updates borrow by id
-	router.HandleFunc("/api/libraries/{id}", deleteLibrary).Methods("DELETE") // deletes borrow by id
+// newHandler routes requests of the API through middlewares of logs, metrics,
+// traces and CORS.
+func newHandler() http.Handler {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(routeNotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
+
+	router.HandleFunc("/healthz", getHealth).Methods("GET")    // liveness, process is up
+	router.HandleFunc("/readyz", getReady).Methods("GET")      // readiness, database reachable and migrated
+	router.Handle("/metrics", metricsHandler()).Methods("GET") // Prometheus metrics
+
+	router.HandleFunc("/api/books/{id}", getBook).Methods("GET")            // returns book by id
+	router.HandleFunc("/api/books", getBooks).Methods("GET")                // returns all books
+	router.HandleFunc("/api/books", postBook).Methods("POST")               // creates book, returns id of created book
+	router.HandleFunc("/api/books/{id}", putBook).Methods("PUT")            // updates book by id
+	router.HandleFunc("/api/books/{id}", patchBook).Methods("PATCH")        // updates fields of book by id
+	router.HandleFunc("/api/books/{id}", deleteBook).Methods("DELETE")      // deletes book by id
+	router.HandleFunc("/api/books/{id}/holds", getBookHolds).Methods("GET") // returns queue of clients waiting for book by id
+	router.HandleFunc("/api/books/{id}/holds", postHold).Methods("POST")    // queues client for book by id, returns created hold
+
+	router.HandleFunc("/api/clients/{id}", getClient).Methods("GET")                        // returns client by id
+	router.HandleFunc("/api/clients", getClients).Methods("GET")                            // returns all clients
+	router.HandleFunc("/api/clients", postClient).Methods("POST")                           // creates client, returns id of created client
+	router.HandleFunc("/api/clients/{id}", putClient).Methods("PUT")                        // updates client by id
+	router.HandleFunc("/api/clients/{id}", patchClient).Methods("PATCH")                    // updates fields of client by id
+	router.HandleFunc("/api/clients/{id}", deleteClient).Methods("DELETE")                  // deletes client by id
+	router.HandleFunc("/api/clients/{id}/holds", getClientHolds).Methods("GET")             // returns holds of client by id
+	router.HandleFunc("/api/clients/{id}/holds/{hold}", deleteClientHold).Methods("DELETE") // cancels hold of client by id
+	router.HandleFunc("/api/clients/{id}/balance", getBalance).Methods("GET")               // returns what client by id owes
+	router.HandleFunc("/api/clients/{id}/ledger", getLedger).Methods("GET")                 // returns fees, payments and waivers of client by id
+	router.HandleFunc("/api/clients/{id}/charges", postCharge).Methods("POST")              // charges client by id, e.g. for lost book
+	router.HandleFunc("/api/clients/{id}/payments", postPayment).Methods("POST")            // records payment of client by id
+	router.HandleFunc("/api/clients/{id}/waivers", postWaiver).Methods("POST")              // forgives part of balance of client by id
+
+	router.HandleFunc("/api/libraries/{id}", getLibrary).Methods("GET")           // returns borrow by id
+	router.HandleFunc("/api/libraries", getLibraries).Methods("GET")              // returns all borrowed books
+	router.HandleFunc("/api/libraries", postLibrary).Methods("POST")              // creates borrow, returns id of created borrow
+	router.HandleFunc("/api/libraries/{id}", putLibrary).Methods("PUT")           // updates borrow by id
+	router.HandleFunc("/api/libraries/{id}", patchLibrary).Methods("PATCH")       // updates fields of borrow by id
+	router.HandleFunc("/api/libraries/{id}", deleteLibrary).Methods("DELETE")     // deletes borrow by id
+	router.HandleFunc("/api/libraries/{id}/renew", postRenew).Methods("POST")     // moves due date of borrow by id
+	router.HandleFunc("/api/libraries/{id}/renewals", getRenewals).Methods("GET") // returns renewal history of borrow by id
+
+	router.HandleFunc("/api/loans/overdue", getOverdueLoans).Methods("GET") // returns active borrows past due date
+	router.HandleFunc("/api/loans/checkout", postCheckout).Methods("POST")  // lends book to client, returns created borrow
+	router.HandleFunc("/api/loans/{id}/return", postReturn).Methods("POST") // ends borrow by id
+
+	router.HandleFunc("/api/search", getSearch).Methods("GET")   // full-text search of books by name and author
+	router.HandleFunc("/api/suggest", getSuggest).Methods("GET") // typeahead of titles, authors and client names
 
 	cors := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedOrigins:   config.CORS.Origins,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "X-Requested-With", requestIDHeader, "traceparent", "tracestate", "If-Match", "If-None-Match", "If-Modified-Since"},
+		ExposedHeaders:   []string{requestIDHeader, "Link", "ETag", "Last-Modified"},
 		AllowCredentials: true,
 	})
-	handler := cors.Handler(router)
-	log.Fatal(http.ListenAndServe(":10000", handler))
+	return traceRequests(router, logRequests(instrumentRequests(cors.Handler(router))))
+}
+
+func handleRequests() error {
+	server := &http.Server{
+		Addr:         config.Server.Listen,
+		Handler:      newHandler(),
+		ReadTimeout:  config.Server.ReadTimeout,
+		WriteTimeout: config.Server.WriteTimeout,
+		IdleTimeout:  config.Server.IdleTimeout,
+	}
+
+	// Serve until SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go updateHolds(ctx)
+	errServe := make(chan error, 1)
+	go func() {
+		errServe <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errServe:
+		return err
+	case <-ctx.Done():
+	}
+	// second signal during grace period kills the process
+	stop()
+
+	// Stop accepting connections and let in-flight requests finish
+	slog.Info("shutting down", "grace_period", config.Server.ShutdownTimeout.String())
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
+	defer cancel()
+	errShutdown := server.Shutdown(ctxShutdown)
+	if errShutdown != nil {
+		server.Close()
+		return errShutdown
+	}
+	return nil
 }
 
 func main() {
-	getConfig()
+	args := getConfig()
 	log2File()
+	shutdownTracing, errTracing := setupTracing()
+	if errTracing != nil {
+		slog.Error("tracing " + errTracing.Error())
+		os.Exit(1)
+	}
+
+	// Refuse to start without database
+	errWait := waitForStore(store, config.Database.ConnectTimeout, config.Database.RetryInterval, config.Database.RetryMaxInterval)
+	if errWait != nil {
+		slog.Error(errWait.Error())
+		fmt.Fprintln(os.Stderr, errWait)
+		os.Exit(1)
+	}
+
+	// library [flags] migrate up|down|status
+	if len(args) > 0 && args[0] == "migrate" {
+		errMigrate := migrate(args[1:])
+		store.Close()
+		if errMigrate != nil {
+			slog.Error("migrate " + errMigrate.Error())
+			fmt.Println(errMigrate)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Connect and check the server version
-	var version string
-	db.QueryRow("SELECT VERSION()").Scan(&version)
-	log.Println("Connected to:", version)
-	fmt.Println("Connected to:", version)
+	// Bring schema up to date
+	if migrator, ok := store.(Migrator); ok {
+		versions, errMigrate := migrator.MigrateUp(context.Background())
+		if errMigrate != nil {
+			slog.Error(errMigrate.Error())
+			os.Exit(1)
+		}
+		for _, version := range versions {
+			slog.Info("applied migration", "version", version)
+		}
+	}
 
-	handleRequests()
+	// Fill in-process search index
+	if indexer, ok := store.(SearchIndexer); ok {
+		start := time.Now()
+		errIndex := indexer.BuildSearchIndex(context.Background())
+		if errIndex != nil {
+			slog.Error("search index " + errIndex.Error())
+			os.Exit(1)
+		}
+		slog.Info("search index built", "duration_ms", time.Since(start).Milliseconds())
+	}
+
+	// Fill suggestions of /api/suggest
+	errSuggest := buildSuggestions(context.Background())
+	if errSuggest != nil {
+		slog.Error("suggestions " + errSuggest.Error())
+		os.Exit(1)
+	}
+
+	// Check the server version
+	version, errVersion := store.Version(context.Background())
+	if errVersion != nil {
+		slog.Error(errVersion.Error())
+		os.Exit(1)
+	}
+	slog.Info("connected", "version", version)
+	registerStoreMetrics(store)
+	fmt.Println("Connected to:", version)
 
-	defer db.Close()
+	errServe := handleRequests()
+	store.Close()
+	ctxFlush, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownTracing(ctxFlush)
+	cancel()
+	if errServe != nil {
+		slog.Error(errServe.Error())
+		fmt.Fprintln(os.Stderr, errServe)
+		os.Exit(1)
+	}
+	slog.Info("stopped")
 }
 
 // ENDPOINTS -------------------------------------------------------------------------
@@ -158,71 +384,88 @@ func main() {
 
 // GET /api/books/1
 func getBook(w http.ResponseWriter, r *http.Request) {
-	var name, author string
-
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	// validate if id == int
 	int_id, errAtoi := strconv.Atoi(id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("GET /api/books/" + id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/books/"+id+" "+errAtoi.Error())
 		return
 	}
 
 	// repository
-	errScan := db.QueryRow("SELECT name, author FROM book WHERE id = ?", int_id).Scan(&name, &author)
-	if errScan != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/books/" + id + " " + errScan.Error())
+	result, errStore := store.GetBook(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/books/"+id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/books/"+id+" "+errStore.Error())
 		return
 	}
 	// number too low or too high -> empty fields // NOT USED
-	if name == "" || author == "" {
+	if result.Name == "" || result.Author == "" {
 		w.WriteHeader(http.StatusNoContent)
-		log.Println("GET /api/books/" + id + " empty fields")
+		slog.WarnContext(r.Context(), "GET /api/books/"+id+" empty fields")
 		return
 	}
-	book := BookRequest{Name: name, Author: author}
+	if notModified(w, r, etag(result.Version), result.UpdatedAt) {
+		return
+	}
+	book := BookRequest{Name: result.Name, Author: result.Author}
 
 	w.WriteHeader(http.StatusOK)
 	errEncode := json.NewEncoder(w).Encode(book)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/books/" + id + " " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "GET /api/books/"+id+" "+errEncode.Error())
 		return
 	}
 }
 
 // GET /api/books
 func getBooks(w http.ResponseWriter, r *http.Request) {
-	var id int
-	var name, author string
-	var books []Book
+	list, errs := parseList(r, bookFields, "facets")
+	var facets []Facet
+	if value := r.URL.Query().Get("facets"); value != "" {
+		var facetErrs []FieldError
+		facets, facetErrs = parseFacets(value)
+		errs = append(errs, facetErrs...)
+	}
+	if len(errs) > 0 {
+		writeProblem(w, r, problemInvalidParameter, strconv.Itoa(len(errs))+" invalid parameters", errs...)
+		slog.WarnContext(r.Context(), "GET /api/books wrong query "+r.URL.RawQuery)
+		return
+	}
 
 	// repository
-	rows, errQuery := db.Query("SELECT id, name, author FROM book")
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/books " + errQuery.Error())
+	books, total, errStore := store.GetBooks(r.Context(), list.withNext())
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/books "+errStore.Error())
 		return
 	}
-	for rows.Next() {
-		errScan := rows.Scan(&id, &name, &author)
-		if errScan != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Println("GET /api/books " + errScan.Error())
+
+	response := BooksResponse{PageResponse: pageResponse(w, r, list, books, total, bookFields)}
+	if len(facets) > 0 {
+		var errFacets error
+		response.Facets, errFacets = store.BookFacets(r.Context(), list.Filters, facets)
+		if errFacets != nil {
+			writeProblem(w, r, problemInternal, "")
+			slog.ErrorContext(r.Context(), "GET /api/books "+errFacets.Error())
 			return
 		}
-		books = append(books, Book{Id: id, Name: name, Author: author})
 	}
 
 	w.WriteHeader(http.StatusOK)
-	errEncode := json.NewEncoder(w).Encode(books)
+	errEncode := json.NewEncoder(w).Encode(response)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/books " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "GET /api/books "+errEncode.Error())
 		return
 	}
 }
@@ -234,43 +477,38 @@ func postBook(w http.ResponseWriter, r *http.Request) {
 
 	requestBody, errIO := ioutil.ReadAll(r.Body)
 	if errIO != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/books " + errIO.Error())
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/books "+errIO.Error())
 		return
 	}
 	errUnmarshal := json.Unmarshal(requestBody, &payload)
 	if errUnmarshal != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/books " + errUnmarshal.Error())
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "POST /api/books "+errUnmarshal.Error())
 		return
 	}
 	// wrong JSON
-	if payload.Name == "" || payload.Author == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("POST /api/books empty fields in JSON")
+	if errs := validateBook(payload); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "POST /api/books empty fields in JSON")
 		return
 	}
 
 	// repository
-	result, errQuery := db.Exec("INSERT INTO book (Name, Author) VALUES (?, ?)", payload.Name, payload.Author)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/books " + errQuery.Error())
+	id, errStore := store.CreateBook(r.Context(), payload)
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/books "+errStore.Error())
 		return
 	}
-	id, errLII := result.LastInsertId()
-	if errLII != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/books" + errLII.Error())
-		return
-	}
-	response = BookResponse{Id: int(id)}
+	suggestions.PutBook(Book{Id: id, Name: payload.Name, Author: payload.Author})
+	response = BookResponse{Id: id}
 
 	w.WriteHeader(http.StatusCreated)
 	errEncode := json.NewEncoder(w).Encode(response)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/books " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "POST /api/books "+errEncode.Error())
 		return
 	}
 }
@@ -284,40 +522,148 @@ func putBook(w http.ResponseWriter, r *http.Request) {
 	// validate if id == int
 	int_id, errAtoi := strconv.Atoi(vars_id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("PUT /api/books/" + vars_id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" "+errAtoi.Error())
 		return
 	}
 	requestBody, errIO := ioutil.ReadAll(r.Body)
 	if errIO != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/books/" + vars_id + " " + errIO.Error())
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/books/"+vars_id+" "+errIO.Error())
 		return
 	}
 	errUnmarshal := json.Unmarshal(requestBody, &payload)
 	if errUnmarshal != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/books/" + vars_id + " " + errUnmarshal.Error())
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" "+errUnmarshal.Error())
 		return
 	}
 	// wrong JSON or /{id}
-	if payload.Name == "" || payload.Author == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("PUT /api/books/" + vars_id + " wrong JSON or id")
+	if errs := validateBook(BookRequest{Name: payload.Name, Author: payload.Author}); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" wrong JSON or id")
 		return
 	}
 
 	// repository
-	_, errQuery := db.Exec("UPDATE book SET Name = ?, Author = ? WHERE Id = ?", payload.Name, payload.Author, int_id)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/books/" + vars_id + " " + errQuery.Error())
+	current, errStore := store.GetBook(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, etag(current.Version)) {
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
 		return
 	}
+	errStore = store.UpdateBook(r.Context(), int_id, current.Version, BookRequest{Name: payload.Name, Author: payload.Author})
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "book "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "PUT /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	suggestions.PutBook(Book{Id: int_id, Name: payload.Name, Author: payload.Author})
 
+	w.Header().Set("ETag", etag(current.Version+1))
 	w.WriteHeader(http.StatusOK)
 }
 
+// PATCH /api/books/1 merge patch or JSON Patch of BookRequest{}
+func patchBook(w http.ResponseWriter, r *http.Request) {
+	var payload BookRequest
+
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errAtoi.Error())
+		return
+	}
+	requestBody, errIO := ioutil.ReadAll(r.Body)
+	if errIO != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errIO.Error())
+		return
+	}
+
+	// repository
+	book, errStore := store.GetBook(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, etag(book.Version)) {
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
+		return
+	}
+	errPatch := applyPatch(r.Header.Get("Content-Type"), requestBody, BookRequest{Name: book.Name, Author: book.Author}, &payload)
+	if patchProblem(w, r, errPatch) {
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errPatch.Error())
+		return
+	}
+	if errPatch != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errPatch.Error())
+		return
+	}
+	if errs := validateBook(payload); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" wrong JSON")
+		return
+	}
+
+	errStore = store.UpdateBook(r.Context(), int_id, book.Version, payload)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "book "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	book = Book{Id: int_id, Name: payload.Name, Author: payload.Author, Version: book.Version + 1}
+	suggestions.PutBook(book)
+
+	w.Header().Set("ETag", etag(book.Version))
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(book)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "PATCH /api/books/"+vars_id+" "+errEncode.Error())
+		return
+	}
+}
+
 // DELETE /api/books/1
 func deleteBook(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -326,23 +672,49 @@ func deleteBook(w http.ResponseWriter, r *http.Request) {
 	// validate if id == int, id !< 1
 	int_id, errAtoi := strconv.Atoi(vars_id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("DELETE /api/books/" + vars_id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "DELETE /api/books/"+vars_id+" "+errAtoi.Error())
 		return
 	}
 	if int_id < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("DELETE /api/books/" + vars_id + "  id < 1")
+		writeProblem(w, r, problemInvalidID, "id must be positive")
+		slog.WarnContext(r.Context(), "DELETE /api/books/"+vars_id+"  id < 1")
 		return
 	}
 
 	// repository
-	_, errQuery := db.Exec("DELETE FROM book WHERE id = ?", int_id)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("DELETE /api/books/" + vars_id + " " + errQuery.Error())
+	current, errStore := store.GetBook(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "DELETE /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, etag(current.Version)) {
+		slog.WarnContext(r.Context(), "DELETE /api/books/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
+		return
+	}
+	errStore = store.DeleteBook(r.Context(), int_id, current.Version)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "book "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "DELETE /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "book "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "DELETE /api/books/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE /api/books/"+vars_id+" "+errStore.Error())
 		return
 	}
+	suggestions.RemoveBook(int_id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -350,71 +722,72 @@ func deleteBook(w http.ResponseWriter, r *http.Request) {
 
 // GET /api/clients/1
 func getClient(w http.ResponseWriter, r *http.Request) {
-	var name string
-
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	// validate if id == int
 	int_id, errAtoi := strconv.Atoi(id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("GET /api/clients/" + id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+id+" "+errAtoi.Error())
 		return
 	}
 
 	// repository
-	errScan := db.QueryRow("SELECT name FROM client WHERE id = ?", int_id).Scan(&name)
-	if errScan != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/clients/" + id + " " + errScan.Error())
+	result, errStore := store.GetClient(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+id+" "+errStore.Error())
 		return
 	}
 	// number too low or too high -> empty field
-	if name == "" {
+	if result.Name == "" {
 		w.WriteHeader(http.StatusNoContent)
-		log.Println("GET /api/clients/" + id + " empty fields")
+		slog.WarnContext(r.Context(), "GET /api/clients/"+id+" empty fields")
 
 		return
 	}
-	client := ClientRequest{Name: name}
+	if notModified(w, r, etag(result.Version), result.UpdatedAt) {
+		return
+	}
+	client := ClientRequest{Name: result.Name}
 	w.WriteHeader(http.StatusOK)
 	errEncode := json.NewEncoder(w).Encode(client)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/clients/" + id + " " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "GET /api/clients/"+id+" "+errEncode.Error())
 		return
 	}
 }
 
 // GET /api/clients
 func getClients(w http.ResponseWriter, r *http.Request) {
-	var id int
-	var name string
-	var clients []Client
+	list, errs := parseList(r, clientFields)
+	if len(errs) > 0 {
+		writeProblem(w, r, problemInvalidParameter, strconv.Itoa(len(errs))+" invalid parameters", errs...)
+		slog.WarnContext(r.Context(), "GET /api/clients/ wrong query "+r.URL.RawQuery)
+		return
+	}
 
 	// repository
-	rows, errQuery := db.Query("SELECT id, name FROM client")
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/clients/ " + errQuery.Error())
+	clients, total, errStore := store.GetClients(r.Context(), list.withNext())
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/clients/ "+errStore.Error())
 		return
 	}
-	for rows.Next() {
-		errScan := rows.Scan(&id, &name)
-		if errScan != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Println("GET /api/clients/ " + errScan.Error())
-			return
-		}
-		clients = append(clients, Client{Id: id, Name: name})
-	}
 
+	response := pageResponse(w, r, list, clients, total, clientFields)
 	w.WriteHeader(http.StatusOK)
-	errEncode := json.NewEncoder(w).Encode(clients)
+	errEncode := json.NewEncoder(w).Encode(response)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/clients/ " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "GET /api/clients/ "+errEncode.Error())
 		return
 	}
 }
@@ -426,43 +799,38 @@ func postClient(w http.ResponseWriter, r *http.Request) {
 
 	requestBody, errIO := ioutil.ReadAll(r.Body)
 	if errIO != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/clients/ " + errIO.Error())
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/clients/ "+errIO.Error())
 		return
 	}
 	errUnmarshal := json.Unmarshal(requestBody, &payload)
 	if errUnmarshal != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/clients/ " + errUnmarshal.Error())
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "POST /api/clients/ "+errUnmarshal.Error())
 		return
 	}
 	// wrong JSON
-	if payload.Name == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("POST /api/clients/  empty fields in JSON")
+	if errs := validateClient(payload); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "POST /api/clients/  empty fields in JSON")
 		return
 	}
 
 	// repository
-	result, errQuery := db.Exec("INSERT INTO client (Name) VALUES (?)", payload.Name)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/clients/ " + errQuery.Error())
+	id, errStore := store.CreateClient(r.Context(), payload)
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/clients/ "+errStore.Error())
 		return
 	}
-	id, errLII := result.LastInsertId()
-	if errLII != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/clients/ " + errLII.Error())
-		return
-	}
-	response = ClientResponse{Id: int(id)}
+	suggestions.PutClient(Client{Id: id, Name: payload.Name})
+	response = ClientResponse{Id: id}
 
 	w.WriteHeader(http.StatusCreated)
 	errEncode := json.NewEncoder(w).Encode(response)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/clients " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "POST /api/clients "+errEncode.Error())
 		return
 	}
 }
@@ -476,40 +844,148 @@ func putClient(w http.ResponseWriter, r *http.Request) {
 	// validate if id == int
 	int_id, errAtoi := strconv.Atoi(vars_id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("PUT /api/clients/" + vars_id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errAtoi.Error())
 		return
 	}
 	requestBody, errIO := ioutil.ReadAll(r.Body)
 	if errIO != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/clients/" + vars_id + " " + errIO.Error())
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errIO.Error())
 		return
 	}
 	errUnmarshal := json.Unmarshal(requestBody, &payload)
 	if errUnmarshal != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/clients/" + vars_id + " " + errUnmarshal.Error())
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errUnmarshal.Error())
 		return
 	}
 	// wrong JSON or /{id}
-	if payload.Name == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("PUT /api/clients/" + vars_id + "  wrong JSON or id")
+	if errs := validateClient(ClientRequest{Name: payload.Name}); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+"  wrong JSON or id")
 		return
 	}
 
 	// repository
-	_, errQuery := db.Exec("UPDATE client SET Name = ? WHERE Id = ?", payload.Name, int_id)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/clients/" + vars_id + " " + errQuery.Error())
+	current, errStore := store.GetClient(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, etag(current.Version)) {
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
 		return
 	}
+	errStore = store.UpdateClient(r.Context(), int_id, current.Version, ClientRequest{Name: payload.Name})
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "client "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	suggestions.PutClient(Client{Id: int_id, Name: payload.Name})
 
+	w.Header().Set("ETag", etag(current.Version+1))
 	w.WriteHeader(http.StatusOK)
 }
 
+// PATCH /api/clients/1 merge patch or JSON Patch of ClientRequest{}
+func patchClient(w http.ResponseWriter, r *http.Request) {
+	var payload ClientRequest
+
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errAtoi.Error())
+		return
+	}
+	requestBody, errIO := ioutil.ReadAll(r.Body)
+	if errIO != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errIO.Error())
+		return
+	}
+
+	// repository
+	client, errStore := store.GetClient(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, etag(client.Version)) {
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
+		return
+	}
+	errPatch := applyPatch(r.Header.Get("Content-Type"), requestBody, ClientRequest{Name: client.Name}, &payload)
+	if patchProblem(w, r, errPatch) {
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errPatch.Error())
+		return
+	}
+	if errPatch != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errPatch.Error())
+		return
+	}
+	if errs := validateClient(payload); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" wrong JSON")
+		return
+	}
+
+	errStore = store.UpdateClient(r.Context(), int_id, client.Version, payload)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "client "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	client = Client{Id: int_id, Name: payload.Name, Version: client.Version + 1}
+	suggestions.PutClient(client)
+
+	w.Header().Set("ETag", etag(client.Version))
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(client)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "PATCH /api/clients/"+vars_id+" "+errEncode.Error())
+		return
+	}
+}
+
 // DELETE /api/clients/1
 func deleteClient(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -518,23 +994,49 @@ func deleteClient(w http.ResponseWriter, r *http.Request) {
 	// validate if id == int, id ! < 1
 	int_id, errAtoi := strconv.Atoi(vars_id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("DELETE /api/clients/" + vars_id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "DELETE /api/clients/"+vars_id+" "+errAtoi.Error())
 		return
 	}
 	if int_id < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("DELETE /api/clients/" + vars_id + "  id < 1")
+		writeProblem(w, r, problemInvalidID, "id must be positive")
+		slog.WarnContext(r.Context(), "DELETE /api/clients/"+vars_id+"  id < 1")
 		return
 	}
 
 	// repository
-	_, errQuery := db.Exec("DELETE FROM client WHERE id = ?", vars_id)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("DELETE /api/clients/" + vars_id + " " + errQuery.Error())
+	current, errStore := store.GetClient(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "DELETE /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, etag(current.Version)) {
+		slog.WarnContext(r.Context(), "DELETE /api/clients/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
 		return
 	}
+	errStore = store.DeleteClient(r.Context(), int_id, current.Version)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "client "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "DELETE /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "client "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "DELETE /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE /api/clients/"+vars_id+" "+errStore.Error())
+		return
+	}
+	suggestions.RemoveClient(int_id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -542,81 +1044,71 @@ func deleteClient(w http.ResponseWriter, r *http.Request) {
 
 // GET /api/libraries/1
 func getLibrary(w http.ResponseWriter, r *http.Request) {
-	var idBook, idClient int
-	var bookName, bookAuthor, clientName, date string
-	var active bool
-
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	// validate if id == int
 	int_id, errAtoi := strconv.Atoi(id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("GET /api/libraries/" + id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/libraries/"+id+" "+errAtoi.Error())
 		return
 	}
 
 	// repository
-	errScan := db.QueryRow("SELECT id_book, book.name, book.author, id_client, client.name, date, active FROM library INNER JOIN book ON library.id_book = book.id INNER JOIN client ON library.id_client = client.id WHERE library.id = ?", int_id).Scan(&idBook, &bookName, &bookAuthor, &idClient, &clientName, &date, &active)
-	if errScan != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/libraries/" + id + " " + errScan.Error())
+	result, errStore := store.GetLoan(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/libraries/"+id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/libraries/"+id+" "+errStore.Error())
 		return
 	}
 	// number too low or too high -> empty field
-	if idBook == 0 || idClient == 0 || date == "" {
+	if result.Book.Id == 0 || result.Client.Id == 0 || result.Library.Date == "" {
 		w.WriteHeader(http.StatusNoContent)
-		log.Println("GET /api/libraries/" + id + "  wrong JSON or ID")
+		slog.WarnContext(r.Context(), "GET /api/libraries/"+id+"  wrong JSON or ID")
 		return
 	}
-	library := LibraryRequestJoin{
-		LibraryRequest{Date: date, Active: active},
-		Book{Id: idBook, Name: bookName, Author: bookAuthor},
-		Client{Id: idClient, Name: clientName},
+	if notModified(w, r, loanETag(result), loanModified(result)) {
+		return
 	}
+	// with due date and overdue days, ETag changes with them
 	w.WriteHeader(http.StatusOK)
-	errEncode := json.NewEncoder(w).Encode(library)
+	errEncode := json.NewEncoder(w).Encode(result)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/libraries/" + id + " " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "GET /api/libraries/"+id+" "+errEncode.Error())
 		return
 	}
 }
 
 // GET /api/libraries
 func getLibraries(w http.ResponseWriter, r *http.Request) {
-	var id, id_book, id_client int
-	var bookName, bookAuthor, clientName, date string
-	var active bool
-	var libraries []LibraryJoin
+	list, errs := parseList(r, loanFields)
+	if len(errs) > 0 {
+		writeProblem(w, r, problemInvalidParameter, strconv.Itoa(len(errs))+" invalid parameters", errs...)
+		slog.WarnContext(r.Context(), "GET /api/libraries wrong query "+r.URL.RawQuery)
+		return
+	}
 
 	// repository
-	rows, errQuery := db.Query("SELECT library.id, id_book, book.name, book.author, id_client, client.name, date, active FROM library INNER JOIN book ON library.id_book = book.id INNER JOIN client ON library.id_client = client.id ")
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/libraries" + errQuery.Error())
+	libraries, total, errStore := store.GetLoans(r.Context(), list.withNext())
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/libraries "+errStore.Error())
 		return
 	}
-	for rows.Next() {
-		errScan := rows.Scan(&id, &id_book, &bookName, &bookAuthor, &id_client, &clientName, &date, &active)
-		if errScan != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Println("GET /api/libraries" + errScan.Error())
-			return
-		}
-		libraries = append(libraries, LibraryJoin{
-			Library{Id: id, Date: date, Active: active},
-			Book{Id: id_book, Name: bookName, Author: bookAuthor},
-			Client{Id: id_client, Name: clientName},
-		})
-	}
 
+	response := pageResponse(w, r, list, libraries, total, loanFields)
 	w.WriteHeader(http.StatusOK)
-	errEncode := json.NewEncoder(w).Encode(libraries)
+	errEncode := json.NewEncoder(w).Encode(response)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("GET /api/libraries " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "GET /api/libraries "+errEncode.Error())
 		return
 	}
 }
@@ -628,43 +1120,46 @@ func postLibrary(w http.ResponseWriter, r *http.Request) {
 
 	requestBody, errIO := ioutil.ReadAll(r.Body)
 	if errIO != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/libraries " + errIO.Error())
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/libraries "+errIO.Error())
 		return
 	}
 	errUnmarshal := json.Unmarshal(requestBody, &payload)
 	if errUnmarshal != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/libraries " + errUnmarshal.Error())
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "POST /api/libraries "+errUnmarshal.Error())
 		return
 	}
 	// wrong JSON
-	if payload.Book.Id == 0 || payload.Client.Id == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("POST /api/libraries wrong JSON or ID")
+	if errs := validateLibrary(payload, false); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "POST /api/libraries wrong JSON or ID")
 		return
 	}
 
 	// repository
-	result, errQuery := db.Exec("INSERT INTO library (id_book, id_client, active) VALUES (?, ?, ?)", payload.Book.Id, payload.Client.Id, payload.Library.Active)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/libraries " + errQuery.Error())
+	id, errStore := store.CreateLoan(r.Context(), payload, time.Now().Add(config.Loans.Period))
+	if errors.Is(errStore, ErrBookOnLoan) {
+		writeProblem(w, r, problemBookOnLoan, "book "+strconv.Itoa(payload.Book.Id)+" has an active loan")
+		slog.WarnContext(r.Context(), "POST /api/libraries "+errStore.Error())
 		return
 	}
-	id, errLII := result.LastInsertId()
-	if errLII != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/libraries " + errLII.Error())
+	if referenceProblem(w, r, errStore, payload) {
+		slog.WarnContext(r.Context(), "POST /api/libraries "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/libraries "+errStore.Error())
 		return
 	}
-	response = LibraryResponse{Id: int(id)}
+	response = LibraryResponse{Id: id}
 
 	w.WriteHeader(http.StatusCreated)
 	errEncode := json.NewEncoder(w).Encode(response)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("POST /api/libraries " + errEncode.Error())
+		slog.ErrorContext(r.Context(), "POST /api/libraries "+errEncode.Error())
 		return
 	}
 }
@@ -678,38 +1173,180 @@ func putLibrary(w http.ResponseWriter, r *http.Request) {
 	// validate if id == int
 	int_id, errAtoi := strconv.Atoi(vars_id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("PUT /api/libraries/" + vars_id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errAtoi.Error())
 		return
 	}
 	requestBody, errIO := ioutil.ReadAll(r.Body)
 	if errIO != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/libraries/" + vars_id + " " + errIO.Error())
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errIO.Error())
 		return
 	}
 	errUnmarshal := json.Unmarshal(requestBody, &payload)
 	if errUnmarshal != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/libraries/" + vars_id + " " + errUnmarshal.Error())
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errUnmarshal.Error())
 		return
 	}
 	// wrong JSON or /{id}
-	if payload.Book.Id == 0 || payload.Client.Id == 0 || payload.Library.Date == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("PUT /api/libraries/" + vars_id + " wrong JSON or ID")
+	errs := validateLibrary(payload, true)
+	errs = append(errs, validDate("Library.Date", payload.Library.Date)...)
+	if len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" wrong JSON or ID")
 		return
 	}
 
 	// repository
-	_, errQuery := db.Exec("UPDATE library SET Id_book = ?, Id_client = ?, Date = ?, Active = ? WHERE Id = ?", payload.Book.Id, payload.Client.Id, payload.Library.Date, payload.Library.Active, int_id)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("PUT /api/libraries/" + vars_id + " " + errQuery.Error())
+	current, errStore := store.GetLoan(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, loanETag(current)) {
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
+		return
+	}
+	errStore = store.UpdateLoan(r.Context(), int_id, current.Library.Version, payload, config.Holds.PickupPeriod, config.Fines)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "loan "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrBookOnLoan) {
+		writeProblem(w, r, problemBookOnLoan, "book "+strconv.Itoa(payload.Book.Id)+" has an active loan")
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if referenceProblem(w, r, errStore, payload) {
+		slog.WarnContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PUT /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	// ETag depends also on book and client, they may be other ones now
+	if loan, errGet := store.GetLoan(r.Context(), int_id); errGet == nil {
+		w.Header().Set("ETag", loanETag(loan))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PATCH /api/libraries/1 merge patch or JSON Patch of LibraryPatch{}
+func patchLibrary(w http.ResponseWriter, r *http.Request) {
+	var patched LibraryPatch
+
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errAtoi.Error())
+		return
+	}
+	requestBody, errIO := ioutil.ReadAll(r.Body)
+	if errIO != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errIO.Error())
+		return
+	}
+
+	// repository
+	loan, errStore := store.GetLoan(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, loanETag(loan)) {
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
+		return
+	}
+	library := LibraryPatch{
+		LibraryRequest{Date: loan.Library.Date, Active: loan.Library.Active},
+		BookResponse{Id: loan.Book.Id},
+		ClientResponse{Id: loan.Client.Id},
+	}
+	errPatch := applyPatch(r.Header.Get("Content-Type"), requestBody, library, &patched, libraryPatchPaths...)
+	if patchProblem(w, r, errPatch) {
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errPatch.Error())
+		return
+	}
+	if errPatch != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errPatch.Error())
+		return
+	}
+	payload := LibraryRequestJoin{patched.Library, Book{Id: patched.Book.Id}, Client{Id: patched.Client.Id}}
+	errs := validateLibrary(payload, true)
+	errs = append(errs, validDate("Library.Date", payload.Library.Date)...)
+	if len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" wrong JSON")
 		return
 	}
 
+	errStore = store.UpdateLoan(r.Context(), int_id, loan.Library.Version, payload, config.Holds.PickupPeriod, config.Fines)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "loan "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrBookOnLoan) {
+		writeProblem(w, r, problemBookOnLoan, "book "+strconv.Itoa(payload.Book.Id)+" has an active loan")
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if referenceProblem(w, r, errStore, payload) {
+		slog.WarnContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	loan, errStore = store.GetLoan(r.Context(), int_id)
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+
+	w.Header().Set("ETag", loanETag(loan))
 	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(loan)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "PATCH /api/libraries/"+vars_id+" "+errEncode.Error())
+		return
+	}
 }
 
 // DELETE /api/libraries/1
@@ -719,20 +1356,46 @@ func deleteLibrary(w http.ResponseWriter, r *http.Request) {
 	// validate if id == int, id !< 1
 	int_id, errAtoi := strconv.Atoi(vars_id)
 	if errAtoi != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("DELETE /api/libraries/" + vars_id + " " + errAtoi.Error())
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "DELETE /api/libraries/"+vars_id+" "+errAtoi.Error())
 		return
 	}
 	if int_id < 1 {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("DELETE /api/libraries/" + vars_id + "  id < 1")
+		writeProblem(w, r, problemInvalidID, "id must be positive")
+		slog.WarnContext(r.Context(), "DELETE /api/libraries/"+vars_id+"  id < 1")
 		return
 	}
 
 	// repository
-	_, errQuery := db.Exec("DELETE FROM library WHERE id = ?", int_id)
-	if errQuery != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	current, errStore := store.GetLoan(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "DELETE /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if preconditionFailed(w, r, loanETag(current)) {
+		slog.WarnContext(r.Context(), "DELETE /api/libraries/"+vars_id+" If-Match "+r.Header.Get("If-Match"))
+		return
+	}
+	errStore = store.DeleteLoan(r.Context(), int_id, current.Library.Version)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "DELETE /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrVersionConflict) {
+		writeProblem(w, r, problemPreconditionFailed, "loan "+vars_id+" was changed by another request")
+		slog.WarnContext(r.Context(), "DELETE /api/libraries/"+vars_id+" "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "DELETE /api/libraries/"+vars_id+" "+errStore.Error())
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testStores lists storage backends the handler tests run against.
+var testStores = []string{"memory", "sqlite"}
+
+// newTestHandler serves the API from empty store of the backend with default config.
+func newTestHandler(t *testing.T, backend string) http.Handler {
+	t.Helper()
+	config = defaultConfig()
+	switch backend {
+	case "memory":
+		store = newMemoryStore()
+	case "sqlite":
+		sqlite, err := newSQLiteStore(t.TempDir() + "/library.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sqlite.Close() })
+		if _, err := sqlite.MigrateUp(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		store = sqlite
+	default:
+		t.Fatalf("unknown backend %s", backend)
+	}
+	suggestions = newSuggestIndex()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return newHandler()
+}
+
+// forEachStore runs test against handler of every backend in testStores.
+func forEachStore(t *testing.T, test func(t *testing.T, h http.Handler)) {
+	for _, backend := range testStores {
+		t.Run(backend, func(t *testing.T) {
+			test(t, newTestHandler(t, backend))
+		})
+	}
+}
+
+// serve sends request with body (none when empty) and headers given as name, value pairs.
+func serve(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// expect checks status of response and, for problems, their code.
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if code == "" {
+		return
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", contentType)
+	}
+	var problem Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Code != code || problem.Status != status {
+		t.Errorf("problem = %+v, %v, want code %s", problem, err, code)
+	}
+}
+
+func decodeBody[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.NewDecoder(w.Body).Decode(&value); err != nil {
+		t.Fatalf("invalid body %s: %v", w.Body, err)
+	}
+	return value
+}
+
+func TestBooksCRUD(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		w := serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Stanisław Lem"}`)
+		expect(t, w, http.StatusCreated, "")
+		if created := decodeBody[BookResponse](t, w); created.Id != 1 {
+			t.Fatalf("created book %d, want 1", created.Id)
+		}
+		expect(t, serve(h, "POST", "/api/books", `{"Name":`), http.StatusBadRequest, problemInvalidJSON.Code)
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Eden"}`), http.StatusBadRequest, problemValidation.Code)
+
+		w = serve(h, "GET", "/api/books/1", "")
+		expect(t, w, http.StatusOK, "")
+		if book := decodeBody[BookRequest](t, w); book != (BookRequest{Name: "Solaris", Author: "Stanisław Lem"}) {
+			t.Errorf("GET /api/books/1 = %+v", book)
+		}
+		expect(t, serve(h, "GET", "/api/books/x", ""), http.StatusBadRequest, problemInvalidID.Code)
+		expect(t, serve(h, "GET", "/api/books/9", ""), http.StatusNotFound, problemNotFound.Code)
+
+		w = serve(h, "GET", "/api/books", "")
+		expect(t, w, http.StatusOK, "")
+		if page := decodeBody[PageResponse[Book]](t, w); page.Total != 1 || len(page.Items) != 1 || page.Items[0].Name != "Solaris" {
+			t.Errorf("GET /api/books = %+v", page)
+		}
+
+		expect(t, serve(h, "PUT", "/api/books/1", `{"Name":"Eden","Author":"Lem"}`, "If-Match", `"1"`), http.StatusOK, "")
+		expect(t, serve(h, "PUT", "/api/books/9", `{"Name":"Eden","Author":"Lem"}`, "If-Match", `"1"`), http.StatusNotFound, problemNotFound.Code)
+		expect(t, serve(h, "PUT", "/api/books/1", `{"Name":""}`, "If-Match", `"2"`), http.StatusBadRequest, problemValidation.Code)
+		w = serve(h, "GET", "/api/books/1", "")
+		if book := decodeBody[BookRequest](t, w); book != (BookRequest{Name: "Eden", Author: "Lem"}) {
+			t.Errorf("GET /api/books/1 after PUT = %+v", book)
+		}
+
+		w = serve(h, "PATCH", "/api/books/1", `{"Author":"Stanisław Lem"}`, "Content-Type", mergePatchType, "If-Match", `"2"`)
+		expect(t, w, http.StatusOK, "")
+		if book := decodeBody[Book](t, w); book.Name != "Eden" || book.Author != "Stanisław Lem" {
+			t.Errorf("PATCH /api/books/1 = %+v", book)
+		}
+		expect(t, serve(h, "PATCH", "/api/books/1", `[{"op":"replace","path":"/Name","value":"Fiasco"}]`, "Content-Type", jsonPatchType, "If-Match", `"3"`), http.StatusOK, "")
+		expect(t, serve(h, "PATCH", "/api/books/1", `[{"op":"test","path":"/Name","value":"Eden"}]`, "Content-Type", jsonPatchType, "If-Match", `"4"`), http.StatusConflict, problemPatchConflict.Code)
+		expect(t, serve(h, "PATCH", "/api/books/1", `{"Name":""}`, "Content-Type", mergePatchType, "If-Match", `"4"`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "PATCH", "/api/books/1", `{"Name":"Eden"}`, "Content-Type", "application/json", "If-Match", `"4"`), http.StatusUnsupportedMediaType, problemUnsupportedMediaType.Code)
+		expect(t, serve(h, "PATCH", "/api/books/9", `{"Name":"Eden"}`, "Content-Type", mergePatchType, "If-Match", `"1"`), http.StatusNotFound, problemNotFound.Code)
+		w = serve(h, "GET", "/api/books/1", "")
+		if book := decodeBody[BookRequest](t, w); book != (BookRequest{Name: "Fiasco", Author: "Stanisław Lem"}) {
+			t.Errorf("GET /api/books/1 after PATCH = %+v", book)
+		}
+
+		expect(t, serve(h, "DELETE", "/api/books/1", "", "If-Match", `"4"`), http.StatusNoContent, "")
+		expect(t, serve(h, "DELETE", "/api/books/0", "", "If-Match", `"1"`), http.StatusBadRequest, problemInvalidID.Code)
+		expect(t, serve(h, "GET", "/api/books/1", ""), http.StatusNotFound, problemNotFound.Code)
+		expect(t, serve(h, "DELETE", "/api/books/1", "", "If-Match", `"4"`), http.StatusNotFound, problemNotFound.Code)
+	})
+}
+
+func TestClientsCRUD(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		w := serve(h, "POST", "/api/clients", `{"Name":"Jan"}`)
+		expect(t, w, http.StatusCreated, "")
+		id := decodeBody[ClientResponse](t, w).Id
+		path := "/api/clients/" + strconv.Itoa(id)
+
+		expect(t, serve(h, "POST", "/api/clients", `{}`), http.StatusBadRequest, problemValidation.Code)
+		w = serve(h, "GET", path, "")
+		expect(t, w, http.StatusOK, "")
+		if client := decodeBody[ClientRequest](t, w); client.Name != "Jan" {
+			t.Errorf("GET %s = %+v", path, client)
+		}
+		expect(t, serve(h, "PUT", path, `{"Name":"Anna"}`, "If-Match", `"1"`), http.StatusOK, "")
+		w = serve(h, "GET", path, "")
+		if client := decodeBody[ClientRequest](t, w); client.Name != "Anna" {
+			t.Errorf("GET %s after PUT = %+v", path, client)
+		}
+		expect(t, serve(h, "PATCH", path, `[{"op":"copy","from":"/Name","path":"/Nick"}]`, "Content-Type", jsonPatchType, "If-Match", `"2"`), http.StatusBadRequest, problemValidation.Code)
+		w = serve(h, "PATCH", path, `{"Name":"Ewa"}`, "Content-Type", mergePatchType, "If-Match", `"2"`)
+		expect(t, w, http.StatusOK, "")
+		if client := decodeBody[Client](t, w); client.Name != "Ewa" {
+			t.Errorf("PATCH %s = %+v", path, client)
+		}
+		expect(t, serve(h, "DELETE", path, "", "If-Match", `"3"`), http.StatusNoContent, "")
+		expect(t, serve(h, "GET", path, ""), http.StatusNotFound, problemNotFound.Code)
+	})
+}
+
+func TestLoansCRUD(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		loan := `{"Book":{"Id":1},"Client":{"Id":1}}`
+		w := serve(h, "POST", "/api/libraries", loan)
+		expect(t, w, http.StatusBadRequest, "")
+		// loan of missing book or client is invalid, not missing
+		if problem := decodeBody[Problem](t, w); len(problem.Errors) != 1 || problem.Errors[0].Code != fieldUnknown {
+			t.Errorf("POST /api/libraries of missing book = %+v", problem)
+		}
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+
+		expect(t, serve(h, "POST", "/api/libraries", loan), http.StatusCreated, "")
+		w = serve(h, "GET", "/api/libraries/1", "")
+		expect(t, w, http.StatusOK, "")
+		if got := decodeBody[LibraryJoin](t, w); got.Book.Name != "Solaris" || got.Client.Name != "Jan" || got.Library.Date == "" {
+			t.Errorf("GET /api/libraries/1 = %+v", got)
+		}
+		expect(t, serve(h, "GET", "/api/libraries/9", ""), http.StatusNotFound, problemNotFound.Code)
+		w = serve(h, "PATCH", "/api/libraries/1", `{"Library":{"Active":false}}`, "Content-Type", mergePatchType, "If-Match", `"1.1.1"`)
+		expect(t, w, http.StatusOK, "")
+		if got := decodeBody[LibraryJoin](t, w); got.Library.Active || got.Book.Name != "Solaris" {
+			t.Errorf("PATCH /api/libraries/1 = %+v", got)
+		}
+		expect(t, serve(h, "PATCH", "/api/libraries/1", `[{"op":"replace","path":"/Book/Id","value":9}]`, "Content-Type", jsonPatchType, "If-Match", `"2.1.1"`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "PATCH", "/api/libraries/1", `{"Book":{"Name":"Eden"}}`, "Content-Type", mergePatchType, "If-Match", `"2.1.1"`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "PUT", "/api/libraries/1", `{"Library":{"Date":"yesterday"},"Book":{"Id":1},"Client":{"Id":1}}`, "If-Match", `"2.1.1"`), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "PUT", "/api/libraries/9", `{"Library":{"Date":"2023-01-02T10:00:00Z"},"Book":{"Id":1},"Client":{"Id":1}}`, "If-Match", `"1.1.1"`), http.StatusNotFound, problemNotFound.Code)
+		w = serve(h, "PATCH", "/api/libraries/1", `{"Library":{"Date":"2023-01-02 10:00:00"}}`, "Content-Type", mergePatchType, "If-Match", `"2.1.1"`)
+		expect(t, w, http.StatusOK, "")
+		if got := decodeBody[LibraryJoin](t, w); got.Library.Date != "2023-01-02T10:00:00Z" {
+			t.Errorf("Date after PATCH = %s, want 2023-01-02T10:00:00Z", got.Library.Date)
+		}
+		expect(t, serve(h, "DELETE", "/api/libraries/1", "", "If-Match", `"3.1.1"`), http.StatusNoContent, "")
+		expect(t, serve(h, "DELETE", "/api/libraries/1", "", "If-Match", `"3.1.1"`), http.StatusNotFound, problemNotFound.Code)
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	h := newTestHandler(t, "memory")
+	expect(t, serve(h, "GET", "/api/nothing", ""), http.StatusNotFound, problemRouteNotFound.Code)
+	expect(t, serve(h, "POST", "/api/books/1", ""), http.StatusMethodNotAllowed, problemMethodNotAllowed.Code)
+	expect(t, serve(h, "GET", "/healthz", ""), http.StatusOK, "")
+}
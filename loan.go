@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const day = 24 * time.Hour
+
+// setOverdue computes Overdue and DaysOverdue of loan at time now, a loan is
+// 1 day overdue right after its due date.
+func setOverdue(library *Library, now time.Time) {
+	library.Overdue, library.DaysOverdue = false, 0
+	due, err := parseDate(library.DueDate)
+	if !library.Active || err != nil || !now.After(due) {
+		return
+	}
+	library.Overdue = true
+	library.DaysOverdue = int((now.Sub(due) + day - 1) / day)
+}
+
+// CheckoutRequest lends book to client, only ids are read.
+type CheckoutRequest struct {
+	Book   Book
+	Client Client
+}
+
+func validateCheckout(checkout CheckoutRequest) []FieldError {
+	var errs []FieldError
+	errs = append(errs, requiredId("Book.Id", checkout.Book.Id)...)
+	errs = append(errs, requiredId("Client.Id", checkout.Client.Id)...)
+	return errs
+}
+
+// POST /api/loans/checkout CheckoutRequest{}
+func postCheckout(w http.ResponseWriter, r *http.Request) {
+	var payload CheckoutRequest
+
+	requestBody, errIO := ioutil.ReadAll(r.Body)
+	if errIO != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/loans/checkout "+errIO.Error())
+		return
+	}
+	errUnmarshal := json.Unmarshal(requestBody, &payload)
+	if errUnmarshal != nil {
+		writeProblem(w, r, problemInvalidJSON, errUnmarshal.Error())
+		slog.WarnContext(r.Context(), "POST /api/loans/checkout "+errUnmarshal.Error())
+		return
+	}
+	// wrong JSON
+	if errs := validateCheckout(payload); len(errs) > 0 {
+		writeValidationProblem(w, r, errs)
+		slog.WarnContext(r.Context(), "POST /api/loans/checkout wrong JSON or ID")
+		return
+	}
+
+	// repository
+	loan, errStore := store.Checkout(r.Context(), payload.Book.Id, payload.Client.Id, time.Now().Add(config.Loans.Period))
+	if errors.Is(errStore, ErrBookOnLoan) {
+		writeProblem(w, r, problemBookOnLoan, "book "+strconv.Itoa(payload.Book.Id)+" has an active loan")
+		slog.WarnContext(r.Context(), "POST /api/loans/checkout "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrBookOnHold) {
+		writeProblem(w, r, problemBookOnHold, "book "+strconv.Itoa(payload.Book.Id)+" is held for another client")
+		slog.WarnContext(r.Context(), "POST /api/loans/checkout "+errStore.Error())
+		return
+	}
+	if referenceProblem(w, r, errStore, LibraryRequestJoin{Book: payload.Book, Client: payload.Client}) {
+		slog.WarnContext(r.Context(), "POST /api/loans/checkout "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/loans/checkout "+errStore.Error())
+		return
+	}
+
+	w.Header().Set("ETag", loanETag(loan))
+	w.WriteHeader(http.StatusCreated)
+	errEncode := json.NewEncoder(w).Encode(loan)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "POST /api/loans/checkout "+errEncode.Error())
+		return
+	}
+}
+
+// POST /api/loans/1/return
+func postReturn(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "POST /api/loans/"+vars_id+"/return "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	loan, errStore := store.ReturnLoan(r.Context(), int_id, config.Holds.PickupPeriod, config.Fines)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "POST /api/loans/"+vars_id+"/return "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrLoanReturned) {
+		writeProblem(w, r, problemLoanReturned, "loan "+vars_id+" is not active")
+		slog.WarnContext(r.Context(), "POST /api/loans/"+vars_id+"/return "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/loans/"+vars_id+"/return "+errStore.Error())
+		return
+	}
+
+	w.Header().Set("ETag", loanETag(loan))
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(loan)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "POST /api/loans/"+vars_id+"/return "+errEncode.Error())
+		return
+	}
+}
+
+// GET /api/loans/overdue
+func getOverdueLoans(w http.ResponseWriter, r *http.Request) {
+	list, errs := parseList(r, loanFields)
+	if len(errs) > 0 {
+		writeProblem(w, r, problemInvalidParameter, strconv.Itoa(len(errs))+" invalid parameters", errs...)
+		slog.WarnContext(r.Context(), "GET /api/loans/overdue wrong query "+r.URL.RawQuery)
+		return
+	}
+	list.Filters = append(list.Filters,
+		Filter{Field: "active", Op: opEqual, Value: true},
+		Filter{Field: "due_date", Op: opBefore, Value: time.Now()},
+	)
+
+	// repository
+	loans, total, errStore := store.GetLoans(r.Context(), list.withNext())
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/loans/overdue "+errStore.Error())
+		return
+	}
+
+	response := pageResponse(w, r, list, loans, total, loanFields)
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET /api/loans/overdue "+errEncode.Error())
+		return
+	}
+}
+
+// POST /api/libraries/1/renew
+func postRenew(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	loan, errStore := store.RenewLoan(r.Context(), int_id, config.Loans.Period, config.Loans.MaxRenewals)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrLoanReturned) {
+		writeProblem(w, r, problemLoanReturned, "loan "+vars_id+" is not active")
+		slog.WarnContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrRenewalLimit) {
+		writeProblem(w, r, problemRenewalLimit, "loan "+vars_id+" was renewed "+strconv.Itoa(config.Loans.MaxRenewals)+" times")
+		slog.WarnContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errStore.Error())
+		return
+	}
+	if errors.Is(errStore, ErrBookOnHold) {
+		writeProblem(w, r, problemBookOnHold, "book of loan "+vars_id+" is held for another client")
+		slog.WarnContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errStore.Error())
+		return
+	}
+
+	w.Header().Set("ETag", loanETag(loan))
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(loan)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "POST /api/libraries/"+vars_id+"/renew "+errEncode.Error())
+		return
+	}
+}
+
+// GET /api/libraries/1/renewals
+func getRenewals(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	vars_id := vars["id"]
+	// validate if id == int
+	int_id, errAtoi := strconv.Atoi(vars_id)
+	if errAtoi != nil {
+		writeProblem(w, r, problemInvalidID, vars_id+" is not a number")
+		slog.WarnContext(r.Context(), "GET /api/libraries/"+vars_id+"/renewals "+errAtoi.Error())
+		return
+	}
+
+	// repository
+	renewals, errStore := store.GetRenewals(r.Context(), int_id)
+	if errors.Is(errStore, ErrNotFound) {
+		writeProblem(w, r, problemNotFound, "loan "+vars_id+" does not exist")
+		slog.WarnContext(r.Context(), "GET /api/libraries/"+vars_id+"/renewals "+errStore.Error())
+		return
+	}
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/libraries/"+vars_id+"/renewals "+errStore.Error())
+		return
+	}
+	response := RenewalsResponse{Items: renewals}
+	if response.Items == nil {
+		response.Items = []Renewal{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET /api/libraries/"+vars_id+"/renewals "+errEncode.Error())
+		return
+	}
+}
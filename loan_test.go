@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetOverdue(t *testing.T) {
+	now := time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		library Library
+		days    int
+	}{
+		{Library{Active: true, DueDate: "2023-02-01T10:00:00Z"}, 0},
+		{Library{Active: true, DueDate: "2023-02-01T09:59:59Z"}, 1},
+		{Library{Active: true, DueDate: "2023-01-31T10:00:00Z"}, 1},
+		{Library{Active: true, DueDate: "2023-01-31 09:00:00"}, 2},
+		{Library{Active: false, DueDate: "2023-01-01T10:00:00Z"}, 0},
+		{Library{Active: true}, 0},
+	}
+	for _, tt := range tests {
+		library := tt.library
+		library.Overdue, library.DaysOverdue = true, 9
+		setOverdue(&library, now)
+		if library.Overdue != (tt.days > 0) || library.DaysOverdue != tt.days {
+			t.Errorf("setOverdue(%+v) = %v, %d days, want %d days", tt.library, library.Overdue, library.DaysOverdue, tt.days)
+		}
+	}
+}
+
+func TestCheckoutAndReturn(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		checkout := `{"Book":{"Id":1},"Client":{"Id":1}}`
+		expect(t, serve(h, "POST", "/api/loans/checkout", checkout), http.StatusBadRequest, problemValidation.Code)
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1}}`), http.StatusBadRequest, problemValidation.Code)
+
+		w := serve(h, "POST", "/api/loans/checkout", checkout)
+		expect(t, w, http.StatusCreated, "")
+		if loan := decodeBody[LibraryJoin](t, w); !loan.Library.Active || loan.Library.Date == "" || loan.Book.Name != "Solaris" || w.Header().Get("ETag") == "" {
+			t.Errorf("checkout = %+v", loan)
+		}
+		expect(t, serve(h, "POST", "/api/loans/checkout", checkout), http.StatusConflict, problemBookOnLoan.Code)
+
+		expect(t, serve(h, "POST", "/api/loans/9/return", ""), http.StatusNotFound, problemNotFound.Code)
+		w = serve(h, "POST", "/api/loans/1/return", "")
+		expect(t, w, http.StatusOK, "")
+		if loan := decodeBody[LibraryJoin](t, w); loan.Library.Active || loan.Library.ReturnDate == "" {
+			t.Errorf("return = %+v", loan)
+		}
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusConflict, problemLoanReturned.Code)
+		// returned book can be borrowed again
+		expect(t, serve(h, "POST", "/api/loans/checkout", checkout), http.StatusCreated, "")
+	})
+}
+
+func TestLoanCorrections(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		newHoldsFixture(t, h)
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`), http.StatusCreated, "")
+		// loan 2 is due 3 started days ago, after return of loan 1
+		expect(t, serve(h, "POST", "/api/libraries", `{"Library":{"Active":true},"Book":{"Id":1},"Client":{"Id":3}}`), http.StatusConflict, problemBookOnLoan.Code)
+		loan := LibraryRequestJoin{Book: Book{Id: 1}, Client: Client{Id: 3}}
+		if _, err := store.CreateLoan(context.Background(), loan, time.Now().Add(-2*day-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		put := `{"Library":{"Date":"2023-01-02T10:00:00Z","Active":true},"Book":{"Id":1},"Client":{"Id":3}}`
+		expect(t, serve(h, "PUT", "/api/libraries/2", put, "If-Match", "*"), http.StatusConflict, problemBookOnLoan.Code)
+
+		// inactive loan is returned, the book is ready for the hold
+		w := serve(h, "PATCH", "/api/libraries/1", `{"Library":{"Active":false}}`, "Content-Type", mergePatchType, "If-Match", "*")
+		expect(t, w, http.StatusOK, "")
+		if got := decodeBody[LibraryJoin](t, w); got.Library.Active || got.Library.ReturnDate == "" {
+			t.Errorf("PATCH of Active = %+v, want returned loan", got)
+		}
+		if holds, want := holdStatuses(t, h, "/api/books/1/holds"), []Hold{{Client: Client{Id: 2}, Status: holdReady, Position: 1}}; !slices.Equal(holds, want) {
+			t.Errorf("holds after PATCH = %+v, want %+v", holds, want)
+		}
+
+		expect(t, serve(h, "PUT", "/api/libraries/2", put, "If-Match", "*"), http.StatusOK, "")
+		w = serve(h, "GET", "/api/libraries/2", "")
+		if got := decodeBody[LibraryJoin](t, w); !got.Library.Active || got.Library.ReturnDate != "" {
+			t.Errorf("PUT of Active = %+v, want active loan", got)
+		}
+		expect(t, serve(h, "PATCH", "/api/libraries/1", `{"Library":{"Active":true}}`, "Content-Type", mergePatchType, "If-Match", "*"), http.StatusConflict, problemBookOnLoan.Code)
+		expect(t, serve(h, "PATCH", "/api/libraries/2", `{"Library":{"Active":false}}`, "Content-Type", mergePatchType, "If-Match", "*"), http.StatusOK, "")
+		w = serve(h, "GET", "/api/clients/3/ledger", "")
+		expect(t, w, http.StatusOK, "")
+		if entries := decodeBody[LedgerResponse](t, w).Items; len(entries) != 1 || entries[0].Loan != 2 || entries[0].Note != overdueNote(3) {
+			t.Errorf("ledger = %+v, want fine of loan 2", entries)
+		}
+	})
+}
+
+func TestConcurrentCheckout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+
+		var wg sync.WaitGroup
+		statuses := make(chan int, 10)
+		for i := 0; i < cap(statuses); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses <- serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":1}}`).Code
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+		counts := make(map[int]int)
+		for status := range statuses {
+			counts[status]++
+		}
+		if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != cap(statuses)-1 {
+			t.Errorf("statuses of concurrent checkouts = %v, want one 201", counts)
+		}
+	})
+}
+
+func TestOverdueLoans(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		ctx := context.Background()
+		for _, name := range []string{"Solaris", "Eden", "Ubik"} {
+			expect(t, serve(h, "POST", "/api/books", `{"Name":"`+name+`","Author":"Lem"}`), http.StatusCreated, "")
+		}
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		now := time.Now()
+		// due 3 days ago, due 1 hour ago and due in a month
+		for book, due := range []time.Time{now.Add(-3*day + time.Hour), now.Add(-time.Hour), now.Add(config.Loans.Period)} {
+			loan := LibraryRequestJoin{Library: LibraryRequest{Active: true}, Book: Book{Id: book + 1}, Client: Client{Id: 1}}
+			if _, err := store.CreateLoan(ctx, loan, due); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		w := serve(h, "GET", "/api/loans/overdue?sort=due_date", "")
+		expect(t, w, http.StatusOK, "")
+		page := decodeBody[PageResponse[LibraryJoin]](t, w)
+		if page.Total != 2 || len(page.Items) != 2 {
+			t.Fatalf("GET /api/loans/overdue = %+v", page)
+		}
+		if first, second := page.Items[0].Library, page.Items[1].Library; first.DaysOverdue != 3 || second.DaysOverdue != 1 || !first.Overdue || !second.Overdue {
+			t.Errorf("overdue loans = %+v, %+v, want 3 and 1 days", first, second)
+		}
+		w = serve(h, "GET", "/api/libraries/1", "")
+		if loan := decodeBody[LibraryJoin](t, w); !loan.Library.Overdue || loan.Library.DaysOverdue != 3 || loan.Library.DueDate == "" {
+			t.Errorf("GET /api/libraries/1 = %+v, want 3 days overdue", loan.Library)
+		}
+		w = serve(h, "PATCH", "/api/libraries/3", `{"Library":{"Date":"2023-01-02T10:00:00Z"}}`, "Content-Type", mergePatchType, "If-Match", `"1.1.1"`)
+		expect(t, w, http.StatusOK, "")
+		if loan := decodeBody[LibraryJoin](t, w); loan.Library.Overdue || loan.Library.DueDate == "" || loan.Library.Id != 3 {
+			t.Errorf("PATCH of loan due in a month = %+v", loan.Library)
+		}
+
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusOK, "")
+		w = serve(h, "GET", "/api/loans/overdue", "")
+		if page := decodeBody[PageResponse[LibraryJoin]](t, w); page.Total != 1 || page.Items[0].Book.Name != "Eden" {
+			t.Errorf("overdue loans after return = %+v", page)
+		}
+		expect(t, serve(h, "GET", "/api/loans/overdue?due=1", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+	})
+}
+
+func TestRenewals(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		w := serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":1}}`)
+		expect(t, w, http.StatusCreated, "")
+		due := decodeBody[LibraryJoin](t, w).Library.DueDate
+
+		for i := 1; i <= config.Loans.MaxRenewals; i++ {
+			w := serve(h, "POST", "/api/libraries/1/renew", "")
+			expect(t, w, http.StatusOK, "")
+			if loan := decodeBody[LibraryJoin](t, w); loan.Library.Renewals != i || loan.Library.DueDate <= due {
+				t.Errorf("renewal %d = %+v, was due %s", i, loan.Library, due)
+			}
+		}
+		expect(t, serve(h, "POST", "/api/libraries/1/renew", ""), http.StatusConflict, problemRenewalLimit.Code)
+		expect(t, serve(h, "POST", "/api/libraries/9/renew", ""), http.StatusNotFound, problemNotFound.Code)
+
+		w = serve(h, "GET", "/api/libraries/1/renewals", "")
+		expect(t, w, http.StatusOK, "")
+		renewals := decodeBody[RenewalsResponse](t, w).Items
+		if len(renewals) != config.Loans.MaxRenewals || renewals[0].PreviousDueDate != due || renewals[1].PreviousDueDate != renewals[0].DueDate {
+			t.Errorf("renewals = %+v, want %d from %s", renewals, config.Loans.MaxRenewals, due)
+		}
+		expect(t, serve(h, "GET", "/api/libraries/9/renewals", ""), http.StatusNotFound, problemNotFound.Code)
+
+		config.Loans.MaxRenewals++
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusOK, "")
+		expect(t, serve(h, "POST", "/api/libraries/1/renew", ""), http.StatusConflict, problemLoanReturned.Code)
+	})
+}
+
+func TestDateFormat(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Solaris","Author":"Lem"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Jan"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/clients", `{"Name":"Anna"}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/loans/checkout", `{"Book":{"Id":1},"Client":{"Id":1}}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/libraries/1/renew", ""), http.StatusOK, "")
+		expect(t, serve(h, "POST", "/api/books/1/holds", `{"Client":{"Id":2}}`), http.StatusCreated, "")
+		expect(t, serve(h, "POST", "/api/loans/1/return", ""), http.StatusOK, "")
+		expect(t, serve(h, "POST", "/api/clients/1/charges", `{"Amount":"1","Loan":1}`), http.StatusCreated, "")
+
+		loan := decodeBody[LibraryJoin](t, serve(h, "GET", "/api/libraries/1", "")).Library
+		renewal := decodeBody[RenewalsResponse](t, serve(h, "GET", "/api/libraries/1/renewals", "")).Items[0]
+		hold := decodeBody[HoldsResponse](t, serve(h, "GET", "/api/books/1/holds", "")).Items[0]
+		entry := decodeBody[LedgerResponse](t, serve(h, "GET", "/api/clients/1/ledger", "")).Items[0]
+		dates := map[string]string{
+			"Library.Date":            loan.Date,
+			"Library.DueDate":         loan.DueDate,
+			"Library.ReturnDate":      loan.ReturnDate,
+			"Renewal.Date":            renewal.Date,
+			"Renewal.PreviousDueDate": renewal.PreviousDueDate,
+			"Renewal.DueDate":         renewal.DueDate,
+			"Hold.Date":               hold.Date,
+			"Hold.ReadyDate":          hold.ReadyDate,
+			"Hold.ExpiryDate":         hold.ExpiryDate,
+			"LedgerEntry.Date":        entry.Date,
+		}
+		for name, date := range dates {
+			if parsed, err := time.Parse(time.RFC3339, date); err != nil || parsed.UTC().Format(time.RFC3339) != date {
+				t.Errorf("%s = %q, want RFC 3339 in UTC", name, date)
+			}
+		}
+	})
+}
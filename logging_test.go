@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestLogRequests(t *testing.T) {
+	h := newTestHandler(t, "memory")
+	var output bytes.Buffer
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(&output, nil)}))
+
+	w := serve(h, "GET", "/api/books/x", "", requestIDHeader, "abc-1")
+	expect(t, w, http.StatusBadRequest, problemInvalidID.Code)
+	if id := w.Header().Get(requestIDHeader); id != "abc-1" {
+		t.Errorf("%s = %q, want abc-1", requestIDHeader, id)
+	}
+
+	// warning of the handler and record of the request, both with id of the request
+	var records []map[string]any
+	decoder := json.NewDecoder(&output)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("logged %d records, want 2: %v", len(records), records)
+	}
+	for _, record := range records {
+		if record["request_id"] != "abc-1" {
+			t.Errorf("request_id of %v, want abc-1", record)
+		}
+	}
+	request := records[1]
+	if request["msg"] != "request" || request["route"] != "/api/books/{id}" || request["path"] != "/api/books/x" || request["status"] != 400.0 {
+		t.Errorf("request record = %v", request)
+	}
+
+	// ids with spaces or control characters are replaced
+	w = serve(h, "GET", "/healthz", "", requestIDHeader, "a b")
+	if id := w.Header().Get(requestIDHeader); len(id) != 32 {
+		t.Errorf("%s = %q, want generated id", requestIDHeader, id)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsTimeout = 2 * time.Second
+
+var (
+	registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "library_http_requests_total",
+		Help: "HTTP requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "library_http_request_duration_seconds",
+		Help:    "HTTP request latency by route template, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "library_db_query_duration_seconds",
+		Help:    "Database statement latency by statement type (SELECT, INSERT, ...).",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"statement"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		dbQueryDuration,
+	)
+}
+
+// registerStoreMetrics adds metrics read from store on every scrape.
+func registerStoreMetrics(store Store) {
+	if s, ok := store.(interface{ DB() *sql.DB }); ok {
+		// go_sql_* pool stats: open, in use, idle, wait count...
+		registry.MustRegister(collectors.NewDBStatsCollector(s.DB(), config.Database.Driver))
+	}
+	registry.MustRegister(loanCollector{store})
+}
+
+// GET /metrics
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError)})
+}
+
+// instrumentRequests counts requests and measures their latency.
+func instrumentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		route, status := requestRoute(r.Context()), strconv.Itoa(rec.status)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// observeQuery records latency of SQL statement (SELECT, INSERT...) started at start.
+func observeQuery(statement string, start time.Time) {
+	dbQueryDuration.WithLabelValues(statement).Observe(time.Since(start).Seconds())
+}
+
+var (
+	loansActiveDesc  = prometheus.NewDesc("library_loans_active", "Borrowed books not returned yet (library.Active = 1).", nil, nil)
+	loansOverdueDesc = prometheus.NewDesc("library_loans_overdue", "Active loans past their due date.", nil, nil)
+)
+
+// loanCollector counts loans in store when metrics are scraped.
+type loanCollector struct {
+	store Store
+}
+
+func (c loanCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- loansActiveDesc
+	ch <- loansOverdueDesc
+}
+
+func (c loanCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+	defer cancel()
+
+	stats, err := c.store.LoanStats(ctx, time.Now())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(loansActiveDesc, err)
+		ch <- prometheus.NewInvalidMetric(loansOverdueDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(loansActiveDesc, prometheus.GaugeValue, float64(stats.Active))
+	ch <- prometheus.MustNewConstMetric(loansOverdueDesc, prometheus.GaugeValue, float64(stats.Overdue))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	h := newTestHandler(t, "memory")
+	expect(t, serve(h, "GET", "/api/books/x", ""), http.StatusBadRequest, problemInvalidID.Code)
+
+	w := serve(h, "GET", "/metrics", "")
+	expect(t, w, http.StatusOK, "")
+	// requests are counted by route template, not by path
+	want := `library_http_requests_total{method="GET",route="/api/books/{id}",status="400"}`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("GET /metrics has no %s", want)
+	}
+}
+
+func TestLoanCollector(t *testing.T) {
+	for _, backend := range testStores {
+		t.Run(backend, func(t *testing.T) {
+			newTestHandler(t, backend)
+			ctx := context.Background()
+			solaris, _ := store.CreateBook(ctx, BookRequest{Name: "Solaris", Author: "Lem"})
+			eden, _ := store.CreateBook(ctx, BookRequest{Name: "Eden", Author: "Lem"})
+			client, _ := store.CreateClient(ctx, ClientRequest{Name: "Jan"})
+			past, future := time.Now().Add(-time.Hour), time.Now().Add(config.Loans.Period)
+			loans := []struct {
+				book   int
+				active bool
+				due    time.Time
+			}{{solaris, true, past}, {eden, true, future}, {solaris, false, past}}
+			for _, loan := range loans {
+				join := LibraryRequestJoin{Library: LibraryRequest{Active: loan.active}, Book: Book{Id: loan.book}, Client: Client{Id: client}}
+				if _, err := store.CreateLoan(ctx, join, loan.due); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(loanCollector{store})
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]float64)
+			for _, family := range families {
+				got[family.GetName()] = family.GetMetric()[0].GetGauge().GetValue()
+			}
+			if got["library_loans_active"] != 2 || got["library_loans_overdue"] != 1 {
+				t.Errorf("loan metrics = %v, want 2 active, 1 overdue", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are kept per dialect in migrations/<driver>/NNNN_name.up.sql with
+// matching NNNN_name.down.sql. Applied versions are recorded in table schema_migrations.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrator is implemented by stores with versioned schema.
+type Migrator interface {
+	// MigrateUp applies all pending migrations and returns their versions.
+	MigrateUp(ctx context.Context) ([]int, error)
+	// MigrateDown reverts the last applied migration and returns its version, 0 if none was applied.
+	MigrateDown(ctx context.Context) (int, error)
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// loadMigrations reads migrations of a dialect sorted by version.
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		version, errAtoi := strconv.Atoi(prefix)
+		if !ok || errAtoi != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.%s.sql", name, direction)
+		}
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version, Name: rest}
+			byVersion[version] = m
+		}
+		if m.Name != rest {
+			return nil, fmt.Errorf("migration %d has files with different names: %s, %s", version, m.Name, rest)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements splits SQL script into statements ending with ; at the end of line.
+// Not every driver executes many statements at once.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// sqlStore
+
+const createMigrationsTable = "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)"
+
+// appliedMigrations returns applied versions with time of applying, none when
+// table schema_migrations does not exist yet. It only reads, /readyz calls it.
+func (s *sqlStore) appliedMigrations(ctx context.Context) (map[int]string, error) {
+	applied := make(map[int]string)
+	var tables int
+	if err := s.queryRow(ctx, s.dialect.tables, "schema_migrations").Scan(&tables); err != nil || tables == 0 {
+		return applied, err
+	}
+	rows, err := s.query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes script in a transaction and records (or removes) version.
+// MySQL commits DDL statements implicitly, so there a failed migration may be applied partially.
+func (s *sqlStore) runMigration(ctx context.Context, m migration, up bool) error {
+	script, record, args := m.Up, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", []any{m.Version, m.Name}
+	if !up {
+		script, record, args = m.Down, "DELETE FROM schema_migrations WHERE version = ?", []any{m.Version}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(record), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) MigrateUp(ctx context.Context) ([]int, error) {
+	migrations, err := loadMigrations(s.dialect.driver)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.exec(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := s.runMigration(ctx, m, true); err != nil {
+			return versions, err
+		}
+		versions = append(versions, m.Version)
+	}
+	return versions, nil
+}
+
+func (s *sqlStore) MigrateDown(ctx context.Context) (int, error) {
+	migrations, err := loadMigrations(s.dialect.driver)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.exec(ctx, createMigrationsTable); err != nil {
+		return 0, err
+	}
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return 0, fmt.Errorf("migration %04d_%s cannot be reverted, it has no down script", m.Version, m.Name)
+		}
+		return m.Version, s.runMigration(ctx, m, false)
+	}
+	return 0, nil
+}
+
+func (s *sqlStore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.dialect.driver)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// migrate runs `library migrate up|down|status` subcommand.
+func migrate(args []string) error {
+	migrator, ok := store.(Migrator)
+	if !ok {
+		return fmt.Errorf("storage backend has no migrations")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: library migrate up|down|status")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		versions, err := migrator.MigrateUp(ctx)
+		for _, version := range versions {
+			fmt.Printf("applied %04d\n", version)
+		}
+		if err == nil && len(versions) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		version, err := migrator.MigrateDown(ctx)
+		if err == nil {
+			if version == 0 {
+				fmt.Println("no applied migrations")
+			} else {
+				fmt.Printf("reverted %04d\n", version)
+			}
+		}
+		return err
+	case "status":
+		statuses, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("%04d_%s applied %s\n", status.Version, status.Name, status.AppliedAt)
+			} else {
+				fmt.Printf("%04d_%s pending\n", status.Version, status.Name)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("usage: library migrate up|down|status")
+	}
+}
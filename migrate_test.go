@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+CREATE TABLE a (
+  id INTEGER
+);
+
+INSERT INTO a VALUES (1);
+DROP TABLE b`
+	want := []string{
+		"CREATE TABLE a (\n  id INTEGER\n);",
+		"INSERT INTO a VALUES (1);",
+		"DROP TABLE b",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements = %q, want %q", got, want)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	var versions []int
+	for _, driver := range []string{"mysql", "sqlite", "postgres"} {
+		migrations, err := loadMigrations(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		var got []int
+		for _, m := range migrations {
+			if m.Down == "" {
+				t.Errorf("%s: migration %04d_%s has no down script", driver, m.Version, m.Name)
+			}
+			got = append(got, m.Version)
+		}
+		// every dialect has the same versions
+		if versions == nil {
+			versions = got
+		} else if !reflect.DeepEqual(got, versions) {
+			t.Errorf("%s: versions %v, want %v", driver, got, versions)
+		}
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	ctx := context.Background()
+	s, err := newSQLiteStore(t.TempDir() + "/library.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := s.MigrateUp(ctx)
+	if err != nil || len(versions) != len(migrations) {
+		t.Fatalf("MigrateUp = %v, %v, want %d versions", versions, err, len(migrations))
+	}
+	if versions, err := s.MigrateUp(ctx); err != nil || len(versions) != 0 {
+		t.Errorf("second MigrateUp = %v, %v, want none", versions, err)
+	}
+	statuses, err := s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("migration %d not applied", status.Version)
+		}
+	}
+
+	// down scripts revert every migration, so all of them apply again
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if version, err := s.MigrateDown(ctx); err != nil || version != migrations[i].Version {
+			t.Fatalf("MigrateDown = %d, %v, want %d", version, err, migrations[i].Version)
+		}
+	}
+	if version, err := s.MigrateDown(ctx); err != nil || version != 0 {
+		t.Errorf("MigrateDown without migrations = %d, %v, want 0", version, err)
+	}
+	if versions, err := s.MigrateUp(ctx); err != nil || len(versions) != len(migrations) {
+		t.Errorf("MigrateUp after down = %v, %v", versions, err)
+	}
+}
+
+func TestMigrateLetters(t *testing.T) {
+	ctx := context.Background()
+	s, err := newSQLiteStore(t.TempDir() + "/library.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := s.MigrateDown(ctx); err != nil || version != 9 {
+		t.Fatalf("MigrateDown = %d, %v, want 9", version, err)
+	}
+	for _, name := range []string{"łąka", "Solaris", "żuk"} {
+		if _, err := s.db.Exec("INSERT INTO book (Name, Author) VALUES (?, 'Lem')", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// existing books get letters of the application
+	if _, err := s.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := s.db.Query("SELECT Letter FROM book ORDER BY ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var letters []string
+	for rows.Next() {
+		var letter string
+		if err := rows.Scan(&letter); err != nil {
+			t.Fatal(err)
+		}
+		letters = append(letters, letter)
+	}
+	if want := []string{"Ł", "S", "Ż"}; !reflect.DeepEqual(letters, want) {
+		t.Errorf("letters = %v, want %v", letters, want)
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE `book` DROP INDEX `FT_Book_Search`;
+ALTER TABLE `book` DROP COLUMN `Search`;
@@ -0,0 +1,9 @@
+-- Full-text search of books (GET /api/search). Search holds words of Name and
+-- Author folded by the application: lowercase, without diacritics.
+ALTER TABLE `book` ADD COLUMN `Search` varchar(255) NOT NULL DEFAULT '';
+
+-- Existing rows are folded approximately, utf8mb4 collation ignores remaining
+-- diacritics when matching; rows are folded exactly on their next update.
+UPDATE `book` SET `Search` = REPLACE(LOWER(CONCAT_WS(' ', `Name`, `Author`)), 'ł', 'l');
+
+ALTER TABLE `book` ADD FULLTEXT INDEX `FT_Book_Search` (`Search`);
@@ -0,0 +1,3 @@
+ALTER TABLE `library` DROP COLUMN `Version`, DROP COLUMN `Updated_At`;
+ALTER TABLE `client` DROP COLUMN `Version`, DROP COLUMN `Updated_At`;
+ALTER TABLE `book` DROP COLUMN `Version`, DROP COLUMN `Updated_At`;
@@ -0,0 +1,9 @@
+-- Optimistic concurrency (ETag, If-Match). Version is incremented by every
+-- update, Updated_At is UTC time of the last change.
+ALTER TABLE `book` ADD COLUMN `Version` int(10) unsigned NOT NULL DEFAULT 1, ADD COLUMN `Updated_At` datetime NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE `client` ADD COLUMN `Version` int(10) unsigned NOT NULL DEFAULT 1, ADD COLUMN `Updated_At` datetime NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE `library` ADD COLUMN `Version` int(10) unsigned NOT NULL DEFAULT 1, ADD COLUMN `Updated_At` datetime NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE `book` SET `Updated_At` = UTC_TIMESTAMP();
+UPDATE `client` SET `Updated_At` = UTC_TIMESTAMP();
+UPDATE `library` SET `Updated_At` = UTC_TIMESTAMP();
@@ -0,0 +1,3 @@
+-- Time the book was returned by POST /api/loans/{id}/return, NULL while on loan
+-- or for loans closed by PUT.
+ALTER TABLE `library` ADD COLUMN `Returned_At` datetime DEFAULT NULL;
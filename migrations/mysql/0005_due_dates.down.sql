@@ -0,0 +1 @@
+ALTER TABLE `library` DROP KEY `library_due_date`, DROP COLUMN `Due_Date`;
@@ -0,0 +1,5 @@
+-- Time the book should be returned, set on checkout from loans.period. Loans
+-- made before get 30 days, the former loans.overdue_after default.
+ALTER TABLE `library` ADD COLUMN `Due_Date` datetime DEFAULT NULL, ADD KEY `library_due_date` (`Due_Date`);
+
+UPDATE `library` SET `Due_Date` = DATE_ADD(`Date`, INTERVAL 30 DAY);
@@ -0,0 +1,14 @@
+-- Renewals of loans: number of them in library, limited by loans.max_renewals,
+-- and history with due date before and after every renewal.
+ALTER TABLE `library` ADD COLUMN `Renewals` int(10) unsigned NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `renewal` (
+  `ID` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `ID_Library` int(10) unsigned NOT NULL,
+  `Date` datetime NOT NULL,
+  `Previous_Due_Date` datetime DEFAULT NULL,
+  `Due_Date` datetime NOT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `renewal_id_library` (`ID_Library`),
+  CONSTRAINT `FK_Renewal_Library` FOREIGN KEY (`ID_Library`) REFERENCES `library` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Renewals of borrowed books.';
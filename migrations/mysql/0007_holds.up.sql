@@ -0,0 +1,16 @@
+-- Holds (reservations) of books on loan, queued by ID per book. Status is
+-- waiting, ready (for pickup until Expires_At), fulfilled, cancelled or expired.
+CREATE TABLE IF NOT EXISTS `hold` (
+  `ID` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `ID_Book` int(10) unsigned NOT NULL,
+  `ID_Client` int(10) unsigned NOT NULL,
+  `Date` datetime NOT NULL,
+  `Status` varchar(10) NOT NULL DEFAULT 'waiting',
+  `Ready_At` datetime DEFAULT NULL,
+  `Expires_At` datetime DEFAULT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `hold_id_book` (`ID_Book`, `Status`),
+  KEY `hold_id_client` (`ID_Client`),
+  CONSTRAINT `FK_Hold_Book` FOREIGN KEY (`ID_Book`) REFERENCES `book` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `FK_Hold_Client` FOREIGN KEY (`ID_Client`) REFERENCES `client` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Queues of clients waiting for borrowed books.';
@@ -0,0 +1,16 @@
+-- Ledger of client fees: charges (overdue fines, lost books) add to balance,
+-- payments and waivers subtract from it. Amount is always positive.
+CREATE TABLE IF NOT EXISTS `ledger` (
+  `ID` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `ID_Client` int(10) unsigned NOT NULL,
+  `ID_Library` int(10) unsigned DEFAULT NULL,
+  `Date` datetime NOT NULL,
+  `Kind` varchar(10) NOT NULL,
+  `Amount` decimal(10,2) NOT NULL,
+  `Note` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`ID`),
+  KEY `ledger_id_client` (`ID_Client`),
+  KEY `ledger_id_library` (`ID_Library`),
+  CONSTRAINT `FK_Ledger_Client` FOREIGN KEY (`ID_Client`) REFERENCES `client` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `FK_Ledger_Library` FOREIGN KEY (`ID_Library`) REFERENCES `library` (`ID`) ON DELETE SET NULL ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Fees, payments and waivers of clients.';
@@ -0,0 +1 @@
+ALTER TABLE `book` DROP KEY `book_letter`, DROP COLUMN `Letter`;
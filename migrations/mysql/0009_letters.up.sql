@@ -0,0 +1,6 @@
+-- First letter of book name, upper case (firstLetter of the application), for
+-- filter, sort and facet letter. Binary collation compares letters like the
+-- application does, utf8mb4 collations would merge S with Ś and L with Ł.
+ALTER TABLE `book` ADD COLUMN `Letter` varchar(1) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '', ADD KEY `book_letter` (`Letter`);
+
+UPDATE `book` SET `Letter` = UPPER(SUBSTR(`Name`, 1, 1));
@@ -0,0 +1,27 @@
+-- Initial schema for PostgreSQL, mirrors mysql/0001_init.up.sql.
+
+CREATE TABLE IF NOT EXISTS book (
+  id serial PRIMARY KEY,
+  name varchar(50) NOT NULL,
+  author varchar(50) DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS client (
+  id serial PRIMARY KEY,
+  name varchar(100) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS library (
+  id serial PRIMARY KEY,
+  id_book integer NOT NULL,
+  id_client integer NOT NULL,
+  date timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  active boolean NOT NULL DEFAULT true,
+  CONSTRAINT fk_library_book FOREIGN KEY (id_book) REFERENCES book (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT fk_library_client FOREIGN KEY (id_client) REFERENCES client (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+COMMENT ON TABLE library IS 'Table for borrowed books.';
+
+CREATE INDEX IF NOT EXISTS library_id_book ON library (id_book);
+CREATE INDEX IF NOT EXISTS library_id_client ON library (id_client);
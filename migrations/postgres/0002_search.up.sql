@@ -0,0 +1,2 @@
+-- Books are searched with in-process index built on start, no schema change.
+-- Kept so migration versions are the same for every backend.
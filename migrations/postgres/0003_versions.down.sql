@@ -0,0 +1,3 @@
+ALTER TABLE library DROP COLUMN version, DROP COLUMN updated_at;
+ALTER TABLE client DROP COLUMN version, DROP COLUMN updated_at;
+ALTER TABLE book DROP COLUMN version, DROP COLUMN updated_at;
@@ -0,0 +1,4 @@
+-- Optimistic concurrency (ETag, If-Match), mirrors mysql/0003_versions.up.sql.
+ALTER TABLE book ADD COLUMN version integer NOT NULL DEFAULT 1, ADD COLUMN updated_at timestamp(0) NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+ALTER TABLE client ADD COLUMN version integer NOT NULL DEFAULT 1, ADD COLUMN updated_at timestamp(0) NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+ALTER TABLE library ADD COLUMN version integer NOT NULL DEFAULT 1, ADD COLUMN updated_at timestamp(0) NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
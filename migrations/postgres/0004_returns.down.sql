@@ -0,0 +1 @@
+ALTER TABLE library DROP COLUMN returned_at;
@@ -0,0 +1,2 @@
+-- Return time of loans, mirrors mysql/0004_returns.up.sql.
+ALTER TABLE library ADD COLUMN returned_at timestamp(0) DEFAULT NULL;
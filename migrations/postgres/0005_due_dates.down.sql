@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS library_due_date;
+ALTER TABLE library DROP COLUMN due_date;
@@ -0,0 +1,5 @@
+-- Due dates of loans, mirrors mysql/0005_due_dates.up.sql.
+ALTER TABLE library ADD COLUMN due_date timestamp(0) DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS library_due_date ON library (due_date);
+
+UPDATE library SET due_date = date + interval '30 days';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS renewal;
+ALTER TABLE library DROP COLUMN renewals;
@@ -0,0 +1,15 @@
+-- Renewals of loans, mirrors mysql/0006_renewals.up.sql.
+ALTER TABLE library ADD COLUMN renewals integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS renewal (
+  id serial PRIMARY KEY,
+  id_library integer NOT NULL,
+  date timestamp(0) NOT NULL,
+  previous_due_date timestamp(0) DEFAULT NULL,
+  due_date timestamp(0) NOT NULL,
+  CONSTRAINT fk_renewal_library FOREIGN KEY (id_library) REFERENCES library (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+COMMENT ON TABLE renewal IS 'Renewals of borrowed books.';
+
+CREATE INDEX IF NOT EXISTS renewal_id_library ON renewal (id_library);
@@ -0,0 +1,17 @@
+-- Holds of books on loan, mirrors mysql/0007_holds.up.sql.
+CREATE TABLE IF NOT EXISTS hold (
+  id serial PRIMARY KEY,
+  id_book integer NOT NULL,
+  id_client integer NOT NULL,
+  date timestamp(0) NOT NULL,
+  status varchar(10) NOT NULL DEFAULT 'waiting',
+  ready_at timestamp(0) DEFAULT NULL,
+  expires_at timestamp(0) DEFAULT NULL,
+  CONSTRAINT fk_hold_book FOREIGN KEY (id_book) REFERENCES book (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT fk_hold_client FOREIGN KEY (id_client) REFERENCES client (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+COMMENT ON TABLE hold IS 'Queues of clients waiting for borrowed books.';
+
+CREATE INDEX IF NOT EXISTS hold_id_book ON hold (id_book, status);
+CREATE INDEX IF NOT EXISTS hold_id_client ON hold (id_client);
@@ -0,0 +1,17 @@
+-- Ledger of client fees, mirrors mysql/0008_ledger.up.sql.
+CREATE TABLE IF NOT EXISTS ledger (
+  id serial PRIMARY KEY,
+  id_client integer NOT NULL,
+  id_library integer DEFAULT NULL,
+  date timestamp(0) NOT NULL,
+  kind varchar(10) NOT NULL,
+  amount numeric(10,2) NOT NULL,
+  note varchar(255) NOT NULL DEFAULT '',
+  CONSTRAINT fk_ledger_client FOREIGN KEY (id_client) REFERENCES client (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT fk_ledger_library FOREIGN KEY (id_library) REFERENCES library (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+COMMENT ON TABLE ledger IS 'Fees, payments and waivers of clients.';
+
+CREATE INDEX IF NOT EXISTS ledger_id_client ON ledger (id_client);
+CREATE INDEX IF NOT EXISTS ledger_id_library ON ledger (id_library);
@@ -0,0 +1,8 @@
+-- First letter of book name, mirrors mysql/0009_letters.up.sql. Collation "C"
+-- orders letters by code point like the application. upper follows LC_CTYPE of
+-- the database, with C locale existing rows get exact letters on their next update.
+ALTER TABLE book ADD COLUMN letter varchar(1) COLLATE "C" NOT NULL DEFAULT '';
+
+UPDATE book SET letter = upper(substr(name, 1, 1));
+
+CREATE INDEX IF NOT EXISTS book_letter ON book (letter);
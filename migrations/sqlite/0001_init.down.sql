@@ -0,0 +1,5 @@
+-- Reverts 0001_init.up.sql, all data is lost.
+
+DROP TABLE IF EXISTS library;
+DROP TABLE IF EXISTS client;
+DROP TABLE IF EXISTS book;
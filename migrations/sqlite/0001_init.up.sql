@@ -0,0 +1,26 @@
+-- Initial schema for SQLite, mirrors mysql/0001_init.up.sql.
+
+CREATE TABLE IF NOT EXISTS `book` (
+  `ID` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `Name` varchar(50) NOT NULL,
+  `Author` varchar(50) DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `client` (
+  `ID` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `Name` varchar(100) NOT NULL DEFAULT ''
+);
+
+-- Table for borrowed books.
+CREATE TABLE IF NOT EXISTS `library` (
+  `ID` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `ID_Book` INTEGER NOT NULL,
+  `ID_Client` INTEGER NOT NULL,
+  `Date` datetime NOT NULL DEFAULT current_timestamp,
+  `Active` tinyint NOT NULL DEFAULT 1,
+  CONSTRAINT `FK_Library_Book` FOREIGN KEY (`ID_Book`) REFERENCES `book` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `FK_Library_Client` FOREIGN KEY (`ID_Client`) REFERENCES `client` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS `Kolumna 2` ON `library` (`ID_Book`);
+CREATE INDEX IF NOT EXISTS `Kolumna 3` ON `library` (`ID_Client`);
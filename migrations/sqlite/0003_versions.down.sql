@@ -0,0 +1,6 @@
+ALTER TABLE `library` DROP COLUMN `Updated_At`;
+ALTER TABLE `library` DROP COLUMN `Version`;
+ALTER TABLE `client` DROP COLUMN `Updated_At`;
+ALTER TABLE `client` DROP COLUMN `Version`;
+ALTER TABLE `book` DROP COLUMN `Updated_At`;
+ALTER TABLE `book` DROP COLUMN `Version`;
@@ -0,0 +1,12 @@
+-- Optimistic concurrency (ETag, If-Match), mirrors mysql/0003_versions.up.sql.
+-- SQLite can't add column with CURRENT_TIMESTAMP default, rows are updated instead.
+ALTER TABLE `book` ADD COLUMN `Version` INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE `book` ADD COLUMN `Updated_At` datetime NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE `client` ADD COLUMN `Version` INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE `client` ADD COLUMN `Updated_At` datetime NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE `library` ADD COLUMN `Version` INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE `library` ADD COLUMN `Updated_At` datetime NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE `book` SET `Updated_At` = CURRENT_TIMESTAMP;
+UPDATE `client` SET `Updated_At` = CURRENT_TIMESTAMP;
+UPDATE `library` SET `Updated_At` = CURRENT_TIMESTAMP;
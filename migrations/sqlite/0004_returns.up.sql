@@ -0,0 +1,2 @@
+-- Return time of loans, mirrors mysql/0004_returns.up.sql.
+ALTER TABLE `library` ADD COLUMN `Returned_At` datetime DEFAULT NULL;
@@ -0,0 +1,5 @@
+-- Due dates of loans, mirrors mysql/0005_due_dates.up.sql.
+ALTER TABLE `library` ADD COLUMN `Due_Date` datetime DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS `library_due_date` ON `library` (`Due_Date`);
+
+UPDATE `library` SET `Due_Date` = datetime(`Date`, '+30 days');
@@ -0,0 +1,13 @@
+-- Renewals of loans, mirrors mysql/0006_renewals.up.sql.
+ALTER TABLE `library` ADD COLUMN `Renewals` INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `renewal` (
+  `ID` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `ID_Library` INTEGER NOT NULL,
+  `Date` datetime NOT NULL,
+  `Previous_Due_Date` datetime DEFAULT NULL,
+  `Due_Date` datetime NOT NULL,
+  CONSTRAINT `FK_Renewal_Library` FOREIGN KEY (`ID_Library`) REFERENCES `library` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS `renewal_id_library` ON `renewal` (`ID_Library`);
@@ -0,0 +1,15 @@
+-- Holds of books on loan, mirrors mysql/0007_holds.up.sql.
+CREATE TABLE IF NOT EXISTS `hold` (
+  `ID` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `ID_Book` INTEGER NOT NULL,
+  `ID_Client` INTEGER NOT NULL,
+  `Date` datetime NOT NULL,
+  `Status` varchar(10) NOT NULL DEFAULT 'waiting',
+  `Ready_At` datetime DEFAULT NULL,
+  `Expires_At` datetime DEFAULT NULL,
+  CONSTRAINT `FK_Hold_Book` FOREIGN KEY (`ID_Book`) REFERENCES `book` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `FK_Hold_Client` FOREIGN KEY (`ID_Client`) REFERENCES `client` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS `hold_id_book` ON `hold` (`ID_Book`, `Status`);
+CREATE INDEX IF NOT EXISTS `hold_id_client` ON `hold` (`ID_Client`);
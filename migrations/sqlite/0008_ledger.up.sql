@@ -0,0 +1,16 @@
+-- Ledger of client fees, mirrors mysql/0008_ledger.up.sql. Amount is text,
+-- numeric affinity would turn decimals into floats.
+CREATE TABLE IF NOT EXISTS `ledger` (
+  `ID` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `ID_Client` INTEGER NOT NULL,
+  `ID_Library` INTEGER DEFAULT NULL,
+  `Date` datetime NOT NULL,
+  `Kind` varchar(10) NOT NULL,
+  `Amount` TEXT NOT NULL,
+  `Note` varchar(255) NOT NULL DEFAULT '',
+  CONSTRAINT `FK_Ledger_Client` FOREIGN KEY (`ID_Client`) REFERENCES `client` (`ID`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `FK_Ledger_Library` FOREIGN KEY (`ID_Library`) REFERENCES `library` (`ID`) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS `ledger_id_client` ON `ledger` (`ID_Client`);
+CREATE INDEX IF NOT EXISTS `ledger_id_library` ON `ledger` (`ID_Library`);
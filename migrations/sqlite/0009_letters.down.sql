@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `book_letter`;
+ALTER TABLE `book` DROP COLUMN `Letter`;
@@ -0,0 +1,7 @@
+-- First letter of book name, mirrors mysql/0009_letters.up.sql. UPPER of SQLite
+-- changes only ASCII letters, first_letter is registered by the application.
+ALTER TABLE `book` ADD COLUMN `Letter` varchar(1) NOT NULL DEFAULT '';
+
+UPDATE `book` SET `Letter` = first_letter(`Name`);
+
+CREATE INDEX IF NOT EXISTS `book_letter` ON `book` (`Letter`);
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PageResponse is body of collection endpoints.
+type PageResponse[T any] struct {
+	Items []T
+	// cursor parameter of the next page, empty on the last page
+	NextCursor string `json:",omitempty"`
+	// number of all items matching filters
+	Total int
+}
+
+// parseList reads filters, sort, limit and cursor or offset query parameters,
+// reserved parameters are left to the handler.
+func parseList[T any](r *http.Request, fields []listField[T], reserved ...string) (ListQuery, []FieldError) {
+	query := r.URL.Query()
+	list := ListQuery{Limit: config.Pagination.DefaultLimit}
+	var errs []FieldError
+
+	filters, filterErrs := parseFilters(query, fields, reserved...)
+	list.Filters, errs = filters, append(errs, filterErrs...)
+	order, sortErrs := parseSort(query, fields)
+	list.Sort, errs = order, append(errs, sortErrs...)
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > config.Pagination.MaxLimit {
+			errs = append(errs, FieldError{Field: "limit", Code: fieldInvalid, Message: "limit must be a number from 1 to " + strconv.Itoa(config.Pagination.MaxLimit)})
+		} else {
+			list.Limit = limit
+		}
+	}
+	cursor, offset := query.Get("cursor"), query.Get("offset")
+	if cursor != "" && offset != "" {
+		errs = append(errs, FieldError{Field: "offset", Code: fieldInvalid, Message: "offset can not be used with cursor"})
+		return list, errs
+	}
+	if cursor != "" && len(sortErrs) == 0 {
+		after, err := decodeCursor(cursor, list.Sort, fields)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "cursor", Code: fieldInvalid, Message: "cursor must be NextCursor of previous page with the same sort"})
+		} else {
+			list.After = after
+		}
+	}
+	if offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			errs = append(errs, FieldError{Field: "offset", Code: fieldInvalid, Message: "offset must be a number, at least 0"})
+		} else {
+			list.Offset = n
+		}
+	}
+	return list, errs
+}
+
+// withNext reads one item more than requested, it tells there is a next page.
+func (q ListQuery) withNext() ListQuery {
+	q.Limit++
+	return q
+}
+
+// cursor is opaque for clients, it hides sort values of the last item of page.
+func encodeCursor[T any](item T, order []Sort, fields []listField[T]) string {
+	values := make([]any, len(order))
+	for i, s := range order {
+		field, _ := findField(fields, s.Field)
+		values[i] = field.Value(item)
+	}
+	b, _ := json.Marshal(values)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor[T any](cursor string, order []Sort, fields []listField[T]) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var values []any
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	if len(values) != len(order) {
+		return nil, errors.New("cursor of different sort")
+	}
+	for i, s := range order {
+		field, _ := findField(fields, s.Field)
+		var ok bool
+		switch value := values[i].(type) {
+		case json.Number:
+			values[i], ok = parseValue(field.Kind, value.String())
+			ok = ok && field.Kind == kindInt
+		case string:
+			values[i], ok = parseValue(field.Kind, value)
+			ok = ok && (field.Kind == kindString || field.Kind == kindDate)
+		case bool:
+			ok = field.Kind == kindBool
+		}
+		if !ok {
+			return nil, fmt.Errorf("cursor value %d is not %s", i, s.Field)
+		}
+	}
+	return values, nil
+}
+
+// pageResponse wraps items read with q.withNext() into envelope and sets
+// RFC 8288 Link header with first, prev, next and last pages.
+func pageResponse[T any](w http.ResponseWriter, r *http.Request, q ListQuery, items []T, total int, fields []listField[T]) PageResponse[T] {
+	response := PageResponse[T]{Items: items, Total: total}
+	if len(items) > q.Limit {
+		response.Items = items[:q.Limit]
+		response.NextCursor = encodeCursor(response.Items[q.Limit-1], q.Sort, fields)
+	}
+	if response.Items == nil {
+		response.Items = []T{}
+	}
+
+	link := func(rel string, params ...string) {
+		query := r.URL.Query()
+		query.Del("cursor")
+		query.Del("offset")
+		query.Set("limit", strconv.Itoa(q.Limit))
+		for i := 0; i+1 < len(params); i += 2 {
+			query.Set(params[i], params[i+1])
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		w.Header().Add("Link", "<"+u.String()+`>; rel="`+rel+`"`)
+	}
+	if r.URL.Query().Has("offset") {
+		link("first", "offset", "0")
+		if q.Offset > 0 {
+			link("prev", "offset", strconv.Itoa(max(q.Offset-q.Limit, 0)))
+		}
+		if response.NextCursor != "" {
+			link("next", "offset", strconv.Itoa(q.Offset+q.Limit))
+		}
+		if total > 0 {
+			link("last", "offset", strconv.Itoa((total-1)/q.Limit*q.Limit))
+		}
+	} else {
+		// cursors go forward only
+		link("first")
+		if response.NextCursor != "" {
+			link("next", "cursor", response.NextCursor)
+		}
+	}
+	return response
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func cursorOf(json string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(json))
+}
+
+func TestDecodeCursor(t *testing.T) {
+	byName := []Sort{{Field: "name"}, {Field: "id"}}
+	byDate := []Sort{{Field: "date", Desc: true}, {Field: "id"}}
+	byActive := []Sort{{Field: "active"}, {Field: "id"}}
+	date := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		cursor string
+		order  []Sort
+		want   []any
+		// cursor is rejected, want is ignored
+		invalid bool
+	}{
+		{"by id", cursorOf(`[7]`), []Sort{{Field: "id"}}, []any{7}, false},
+		{"by name", cursorOf(`["Solaris",7]`), byName, []any{"Solaris", 7}, false},
+		{"by date", cursorOf(`["2023-01-02T15:04:05Z",7]`), byDate, []any{date, 7}, false},
+		{"by bool", cursorOf(`[true,7]`), byActive, []any{true, 7}, false},
+		{"encoded", encodeCursor(LibraryJoin{Library: Library{Id: 7, Date: "2023-01-02T15:04:05Z"}}, byDate, loanFields), byDate, []any{date, 7}, false},
+
+		{"empty", "", byName, nil, true},
+		{"not base64", "not*base64!", byName, nil, true},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`[12]`)), []Sort{{Field: "id"}}, nil, true},
+		{"not JSON", cursorOf(`[7`), []Sort{{Field: "id"}}, nil, true},
+		{"not array", cursorOf(`{"id":7}`), []Sort{{Field: "id"}}, nil, true},
+		{"null", cursorOf(`null`), []Sort{{Field: "id"}}, nil, true},
+		{"other sort", cursorOf(`[7]`), byName, nil, true},
+		{"longer than sort", cursorOf(`["Solaris",7,8]`), byName, nil, true},
+		{"swapped values", cursorOf(`[7,"Solaris"]`), byName, nil, true},
+		{"string id", cursorOf(`["7"]`), []Sort{{Field: "id"}}, nil, true},
+		{"fraction id", cursorOf(`[7.5]`), []Sort{{Field: "id"}}, nil, true},
+		{"huge id", cursorOf(`[1e400]`), []Sort{{Field: "id"}}, nil, true},
+		{"null value", cursorOf(`[null]`), []Sort{{Field: "id"}}, nil, true},
+		{"nested value", cursorOf(`[[7]]`), []Sort{{Field: "id"}}, nil, true},
+		{"number as name", cursorOf(`[1,7]`), byName, nil, true},
+		{"bad date", cursorOf(`["yesterday",7]`), byDate, nil, true},
+		{"string bool", cursorOf(`["true",7]`), byActive, nil, true},
+	}
+	// dates and bools are sorted in loans only
+	decode := func(cursor string, order []Sort) ([]any, error) {
+		if _, ok := findField(bookFields, order[0].Field); ok {
+			return decodeCursor(cursor, order, bookFields)
+		}
+		return decodeCursor(cursor, order, loanFields)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decode(tt.cursor, tt.order)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("decodeCursor(%q) = %v, want error", tt.cursor, got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCursor(%q) = %v, %v, want %v", tt.cursor, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzDecodeCursor(f *testing.F) {
+	for _, seed := range []string{`[7]`, `["Solaris",7]`, `["2023-01-02",7]`, `[true,7]`, `[null,{}]`, `[1e400,-0]`} {
+		f.Add(cursorOf(seed))
+	}
+	orders := [][]Sort{{{Field: "id"}}, {{Field: "date"}, {Field: "id"}}, {{Field: "active"}, {Field: "id"}}, {{Field: "book_id"}, {Field: "id"}}}
+	f.Fuzz(func(t *testing.T, cursor string) {
+		for _, order := range orders {
+			decodeCursor(cursor, order, loanFields)
+		}
+	})
+}
+
+func TestParseList(t *testing.T) {
+	config = defaultConfig()
+	byName := cursorOf(`["Solaris",7]`)
+	tests := []struct {
+		query string
+		want  ListQuery
+		// fields of errors, want is ignored when there are any
+		errs []string
+	}{
+		{"", ListQuery{Sort: []Sort{{Field: "id"}}, Limit: config.Pagination.DefaultLimit}, nil},
+		{"?sort=name&limit=5&cursor=" + byName, ListQuery{Sort: []Sort{{Field: "name"}, {Field: "id"}}, Limit: 5, After: []any{"Solaris", 7}}, nil},
+		{"?author=Lem&offset=10", ListQuery{Filters: []Filter{{Field: "author", Op: opEqual, Value: "Lem"}}, Sort: []Sort{{Field: "id"}}, Limit: config.Pagination.DefaultLimit, Offset: 10}, nil},
+
+		{"?cursor=" + byName, ListQuery{}, []string{"cursor"}},
+		{"?sort=-name&cursor=" + cursorOf(`[7]`), ListQuery{}, []string{"cursor"}},
+		{"?cursor=%25%25", ListQuery{}, []string{"cursor"}},
+		{"?cursor=" + cursorOf(`[7]`) + "&offset=1", ListQuery{}, []string{"offset"}},
+		{"?sort=isbn&cursor=" + cursorOf(`[7]`), ListQuery{}, []string{"sort"}},
+		{"?limit=0", ListQuery{}, []string{"limit"}},
+		{"?limit=1000000", ListQuery{}, []string{"limit"}},
+		{"?limit=ten&offset=-1", ListQuery{}, []string{"limit", "offset"}},
+		{"?isbn=1&id=x", ListQuery{}, []string{"id", "isbn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, errs := parseList(httptest.NewRequest("GET", "/api/books"+tt.query, nil), bookFields)
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.errs) {
+				t.Fatalf("parseList(%s) errors = %v, want %v", tt.query, errs, tt.errs)
+			}
+			if tt.errs == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseList(%s) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// walkPages follows NextCursor from path and returns names of all books.
+func walkPages(t *testing.T, h http.Handler, path string) []string {
+	t.Helper()
+	var names []string
+	for next := path; next != ""; {
+		w := serve(h, "GET", next, "")
+		expect(t, w, http.StatusOK, "")
+		page := decodeBody[PageResponse[Book]](t, w)
+		for _, book := range page.Items {
+			names = append(names, book.Name)
+		}
+		if len(names) > page.Total {
+			t.Fatalf("GET %s: pages have more than %d books", path, page.Total)
+		}
+		next = ""
+		if page.NextCursor != "" {
+			next = path + "&cursor=" + page.NextCursor
+		}
+	}
+	return names
+}
+
+func TestPagination(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		for i := 1; i <= 5; i++ {
+			expect(t, serve(h, "POST", "/api/books", `{"Name":"Book `+strconv.Itoa(i)+`","Author":"Lem"}`), http.StatusCreated, "")
+		}
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Ubik","Author":"Dick"}`), http.StatusCreated, "")
+
+		// cursors walk through all books once
+		if names, want := walkPages(t, h, "/api/books?limit=2"), []string{"Book 1", "Book 2", "Book 3", "Book 4", "Book 5", "Ubik"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("books of pages = %v, want %v", names, want)
+		}
+		if names, want := walkPages(t, h, "/api/books?limit=2&author=Lem&sort=-name"), []string{"Book 5", "Book 4", "Book 3", "Book 2", "Book 1"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("books of Lem by name descending = %v, want %v", names, want)
+		}
+
+		w := serve(h, "GET", "/api/books?limit=2&offset=2", "")
+		expect(t, w, http.StatusOK, "")
+		if page := decodeBody[PageResponse[Book]](t, w); len(page.Items) != 2 || page.Items[0].Name != "Book 3" {
+			t.Errorf("GET /api/books?limit=2&offset=2 = %+v", page)
+		}
+		want := []string{
+			`</api/books?limit=2&offset=0>; rel="first"`,
+			`</api/books?limit=2&offset=0>; rel="prev"`,
+			`</api/books?limit=2&offset=4>; rel="next"`,
+			`</api/books?limit=2&offset=4>; rel="last"`,
+		}
+		if links := w.Header().Values("Link"); !reflect.DeepEqual(links, want) {
+			t.Errorf("Link = %q, want %q", links, want)
+		}
+		expect(t, serve(h, "GET", "/api/books?limit=0", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+	})
+}
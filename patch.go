@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of PATCH request body, RFC 7396 and RFC 6902.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// acceptPatch is Accept-Patch header (RFC 5789) of resources supporting PATCH.
+var acceptPatch = mergePatchType + ", " + jsonPatchType
+
+// patchError is problem sent to client when patch can not be applied.
+type patchError struct {
+	problem problemType
+	detail  string
+	errs    []FieldError
+}
+
+func (e *patchError) Error() string {
+	return e.detail
+}
+
+// patchOperation is an operation of JSON Patch document.
+type patchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from"`
+	// nil when missing, null is "null"
+	Value json.RawMessage `json:"value"`
+}
+
+// applyPatch patches original, marshaled to JSON, with body of media type
+// contentType and decodes the result into patched. Fields not known to patched
+// are rejected, so resulting document has to be validated only. When writable
+// JSON Pointers are given, patches of other paths are rejected too.
+func applyPatch(contentType string, body []byte, original, patched any, writable ...string) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != mergePatchType && mediaType != jsonPatchType {
+		return &patchError{problem: problemUnsupportedMediaType, detail: "Content-Type must be " + mergePatchType + " or " + jsonPatchType}
+	}
+
+	b, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	if mediaType == mergePatchType {
+		var patch any
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return &patchError{problem: problemInvalidJSON, detail: err.Error()}
+		}
+		if writable != nil {
+			if err := checkWritable(patchedPaths("", patch), writable); err != nil {
+				return err
+			}
+		}
+		doc = mergePatch(doc, patch)
+	} else {
+		var operations []patchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return &patchError{problem: problemInvalidJSON, detail: err.Error()}
+		}
+		if writable != nil {
+			var paths []string
+			for _, operation := range operations {
+				paths = append(paths, operation.changedPaths()...)
+			}
+			if err := checkWritable(paths, writable); err != nil {
+				return err
+			}
+		}
+		if doc, err = jsonPatch(doc, operations); err != nil {
+			return err
+		}
+	}
+
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(patched)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return &patchError{problem: problemValidation, detail: "patched document must be an object"}
+	case errors.As(err, &typeErr):
+		return &patchError{problem: problemValidation, detail: "1 invalid fields", errs: []FieldError{{Field: typeErr.Field, Code: fieldInvalid, Message: typeErr.Field + " must be " + jsonKinds[typeErr.Type.Kind()]}}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return &patchError{problem: problemValidation, detail: "1 invalid fields", errs: []FieldError{{Field: field, Code: fieldUnknown, Message: field + " is not a field of this resource"}}}
+	}
+	return err
+}
+
+// patchedPaths lists JSON Pointers of leaves of value set at path, objects are
+// walked into, so {"Book":{"Id":2}} sets /Book/Id only.
+func patchedPaths(path string, value any) []string {
+	object, ok := value.(map[string]any)
+	if !ok || len(object) == 0 {
+		return []string{path}
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var paths []string
+	for _, key := range keys {
+		paths = append(paths, patchedPaths(path+"/"+pointerEscaper.Replace(key), object[key])...)
+	}
+	return paths
+}
+
+// changedPaths lists JSON Pointers operation changes, test changes nothing.
+// Malformed operations are left to apply.
+func (o patchOperation) changedPaths() []string {
+	switch o.Op {
+	case "add", "replace":
+		var value any
+		if json.Unmarshal(o.Value, &value) != nil {
+			return []string{o.Path}
+		}
+		return patchedPaths(o.Path, value)
+	case "move":
+		return []string{o.From, o.Path}
+	case "remove", "copy":
+		return []string{o.Path}
+	}
+	return nil
+}
+
+// checkWritable rejects paths which are not writable. Parents of writable
+// paths may be removed or replaced, their values are checked when decoded.
+// Malformed paths are left to jsonPatch.
+func checkWritable(paths, writable []string) error {
+	var errs []FieldError
+	for _, path := range paths {
+		tokens, err := parsePointer(path)
+		if err != nil {
+			continue
+		}
+		ok := slices.ContainsFunc(writable, func(w string) bool {
+			return w == path || strings.HasPrefix(w, path+"/")
+		})
+		if !ok {
+			field := strings.Join(tokens, ".")
+			errs = append(errs, FieldError{Field: field, Code: fieldInvalid, Message: field + " can not be patched"})
+		}
+	}
+	if len(errs) > 0 {
+		return &patchError{problem: problemValidation, detail: strconv.Itoa(len(errs)) + " invalid fields", errs: errs}
+	}
+	return nil
+}
+
+// jsonKinds describe JSON values of Go types of request fields.
+var jsonKinds = map[reflect.Kind]string{
+	reflect.Bool:   "true or false",
+	reflect.Int:    "a number",
+	reflect.String: "a string",
+	reflect.Struct: "an object",
+}
+
+// patchProblem sends patchError, false for other errors.
+func patchProblem(w http.ResponseWriter, r *http.Request, err error) bool {
+	var patchErr *patchError
+	if !errors.As(err, &patchErr) {
+		return false
+	}
+	if patchErr.problem == problemUnsupportedMediaType {
+		w.Header().Set("Accept-Patch", acceptPatch)
+	}
+	writeProblem(w, r, patchErr.problem, patchErr.detail, patchErr.errs...)
+	return true
+}
+
+// mergePatch applies RFC 7396 merge patch: members of patch object replace
+// members of target, null removes them, other values replace whole target.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// jsonPatch applies RFC 6902 operations in order, all or none.
+func jsonPatch(doc any, operations []patchOperation) (any, error) {
+	for i, operation := range operations {
+		var err error
+		doc, err = operation.apply(doc)
+		if err != nil {
+			var patchErr *patchError
+			if errors.As(err, &patchErr) {
+				patchErr.detail = "operation " + strconv.Itoa(i) + " (" + operation.Op + " " + operation.Path + "): " + patchErr.detail
+			}
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func invalidPatch(format string, args ...any) error {
+	return &patchError{problem: problemInvalidPatch, detail: fmt.Sprintf(format, args...)}
+}
+
+func patchConflict(format string, args ...any) error {
+	return &patchError{problem: problemPatchConflict, detail: fmt.Sprintf(format, args...)}
+}
+
+func (o patchOperation) apply(doc any) (any, error) {
+	path, err := parsePointer(o.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	switch o.Op {
+	case "add", "replace", "test":
+		if o.Value == nil {
+			return nil, invalidPatch("value is missing")
+		}
+		if err := json.Unmarshal(o.Value, &value); err != nil {
+			return nil, invalidPatch("value is not valid JSON")
+		}
+	case "move", "copy":
+		from, err := parsePointer(o.From)
+		if err != nil {
+			return nil, err
+		}
+		if o.Op == "move" && len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+			return nil, invalidPatch("from %s can not be moved into itself", o.From)
+		}
+		if o.Op == "move" {
+			doc, value, err = pointerRemove(doc, from)
+		} else {
+			value, err = pointerGet(doc, from)
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, invalidPatch("op must be add, remove, replace, move, copy or test")
+	}
+
+	switch o.Op {
+	case "add", "move", "copy":
+		return pointerAdd(doc, path, value)
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+		return doc, err
+	case "replace":
+		if doc, _, err = pointerRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+	default: // test
+		current, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, patchConflict("value differs")
+		}
+		return doc, nil
+	}
+}
+
+var (
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+// parsePointer splits RFC 6901 JSON Pointer into reference tokens, "" is the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, invalidPatch("path %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = pointerUnescaper.Replace(tokens[i])
+	}
+	return tokens, nil
+}
+
+// arrayIndex reads token of array of length n, "-" and n are the end when end is allowed.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > n || i == n && !end || token != strconv.Itoa(i) {
+		return 0, patchConflict("array index %s does not exist", token)
+	}
+	return i, nil
+}
+
+func pointerGet(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, patchConflict("member %s does not exist", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, patchConflict("%s is not in object or array", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd returns doc with value added at path, arrays are reallocated.
+func pointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		if last {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, patchConflict("member %s does not exist", token)
+		}
+		child, err := pointerAdd(child, path[1:], value)
+		node[token] = child
+		return node, err
+	case []any:
+		i, err := arrayIndex(token, len(node), last)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			return slices.Insert(node, i, value), nil
+		}
+		node[i], err = pointerAdd(node[i], path[1:], value)
+		return node, err
+	}
+	return nil, patchConflict("%s is not in object or array", token)
+}
+
+// pointerRemove returns doc without value at path and the removed value.
+func pointerRemove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, patchConflict("member %s does not exist", token)
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := pointerRemove(child, path[1:])
+		node[token] = child
+		return node, removed, err
+	case []any:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := node[i]
+			return slices.Delete(node, i, i+1), removed, nil
+		}
+		child, removed, err := pointerRemove(node[i], path[1:])
+		node[i] = child
+		return node, removed, err
+	}
+	return nil, nil, patchConflict("%s is not in object or array", token)
+}
+
+// deepCopy copies decoded JSON value, so copied and original value are not shared.
+func deepCopy(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(value))
+		for key, v := range value {
+			copied[key] = deepCopy(v)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(value))
+		for i, v := range value {
+			copied[i] = deepCopy(v)
+		}
+		return copied
+	}
+	return value
+}
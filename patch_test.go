@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// decodeJSON decodes JSON text into values of mergePatch and jsonPatch.
+func decodeJSON(t *testing.T, text string) any {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("invalid JSON %s: %v", text, err)
+	}
+	return value
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name, target, patch, want string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null removes member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"null of missing member", `{"a":"b"}`, `{"c":null}`, `{"a":"b"}`},
+		{"nested merge", `{"a":{"b":"c","d":"e"}}`, `{"a":{"b":"x","d":null}}`, `{"a":{"b":"x"}}`},
+		{"nested into non object", `{"a":"b"}`, `{"a":{"c":"d"}}`, `{"a":{"c":"d"}}`},
+		{"array replaced whole", `{"a":["b","c"]}`, `{"a":["d"]}`, `{"a":["d"]}`},
+		{"non object patch replaces target", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"empty patch", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch(%s, %s) = %v, want %v", tt.target, tt.patch, got, want)
+			}
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	const doc = `{"a":{"b":"c"},"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`
+	tests := []struct {
+		name       string
+		operations string
+		want       string
+		// problem of failing operations, want is ignored
+		problem problemType
+	}{
+		{"add member", `[{"op":"add","path":"/a/d","value":"e"}]`, `{"a":{"b":"c","d":"e"},"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"add replaces member", `[{"op":"add","path":"/a/b","value":null}]`, `{"a":{"b":null},"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"add into array", `[{"op":"add","path":"/list/1","value":9}]`, `{"a":{"b":"c"},"list":[1,9,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"add at array end", `[{"op":"add","path":"/list/3","value":9}]`, `{"a":{"b":"c"},"list":[1,2,3,9],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"add with index -", `[{"op":"add","path":"/list/-","value":9}]`, `{"a":{"b":"c"},"list":[1,2,3,9],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"add whole document", `[{"op":"add","path":"","value":{"x":1}}]`, `{"x":1}`, problemType{}},
+		{"remove member", `[{"op":"remove","path":"/a"}]`, `{"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"remove from array", `[{"op":"remove","path":"/list/0"}]`, `{"a":{"b":"c"},"list":[2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"replace member", `[{"op":"replace","path":"/a/b","value":["x"]}]`, `{"a":{"b":["x"]},"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"replace last array item", `[{"op":"replace","path":"/list/2","value":9}]`, `{"a":{"b":"c"},"list":[1,2,9],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"move member", `[{"op":"move","from":"/a/b","path":"/b"}]`, `{"a":{},"b":"c","list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"move in array", `[{"op":"move","from":"/list/0","path":"/list/-"}]`, `{"a":{"b":"c"},"list":[2,3,1],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"copy member", `[{"op":"copy","from":"/a","path":"/z"}]`, `{"a":{"b":"c"},"z":{"b":"c"},"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"copy is not shared", `[{"op":"copy","from":"/a","path":"/z"},{"op":"add","path":"/z/b","value":"x"}]`, `{"a":{"b":"c"},"z":{"b":"x"},"list":[1,2,3],"a/b":1,"m~n":2,"~1":3}`, problemType{}},
+		{"test passes", `[{"op":"test","path":"/list","value":[1,2,3]},{"op":"test","path":"/a","value":{"b":"c"}}]`, doc, problemType{}},
+		{"escaped /", `[{"op":"replace","path":"/a~1b","value":9}]`, `{"a":{"b":"c"},"list":[1,2,3],"a/b":9,"m~n":2,"~1":3}`, problemType{}},
+		{"escaped ~", `[{"op":"remove","path":"/m~0n"}]`, `{"a":{"b":"c"},"list":[1,2,3],"a/b":1,"~1":3}`, problemType{}},
+		{"escaped ~ before 1", `[{"op":"test","path":"/~01","value":3}]`, doc, problemType{}},
+		{"operations in order", `[{"op":"add","path":"/x","value":1},{"op":"test","path":"/x","value":1},{"op":"remove","path":"/x"}]`, doc, problemType{}},
+
+		{"test fails", `[{"op":"test","path":"/a/b","value":"x"}]`, "", problemPatchConflict},
+		{"test of other type", `[{"op":"test","path":"/list/0","value":"1"}]`, "", problemPatchConflict},
+		{"test fails after add", `[{"op":"add","path":"/x","value":1},{"op":"test","path":"/x","value":2}]`, "", problemPatchConflict},
+		{"test of missing member", `[{"op":"test","path":"/x","value":null}]`, "", problemPatchConflict},
+		{"remove missing member", `[{"op":"remove","path":"/x"}]`, "", problemPatchConflict},
+		{"replace missing member", `[{"op":"replace","path":"/x","value":1}]`, "", problemPatchConflict},
+		{"add under missing member", `[{"op":"add","path":"/x/y","value":1}]`, "", problemPatchConflict},
+		{"add into scalar", `[{"op":"add","path":"/a/b/c","value":1}]`, "", problemPatchConflict},
+		{"array index out of range", `[{"op":"add","path":"/list/4","value":1}]`, "", problemPatchConflict},
+		{"array index with leading zero", `[{"op":"remove","path":"/list/01"}]`, "", problemPatchConflict},
+		{"negative array index", `[{"op":"remove","path":"/list/-1"}]`, "", problemPatchConflict},
+		{"remove with index -", `[{"op":"remove","path":"/list/-"}]`, "", problemPatchConflict},
+		{"move from missing member", `[{"op":"move","from":"/x","path":"/y"}]`, "", problemPatchConflict},
+		{"move into itself", `[{"op":"move","from":"/a","path":"/a/b"}]`, "", problemInvalidPatch},
+		{"unknown op", `[{"op":"merge","path":"/a","value":1}]`, "", problemInvalidPatch},
+		{"missing value", `[{"op":"add","path":"/a"}]`, "", problemInvalidPatch},
+		{"path without /", `[{"op":"remove","path":"a"}]`, "", problemInvalidPatch},
+		{"from without /", `[{"op":"copy","from":"a","path":"/b"}]`, "", problemInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operations []patchOperation
+			if err := json.Unmarshal([]byte(tt.operations), &operations); err != nil {
+				t.Fatal(err)
+			}
+			got, err := jsonPatch(decodeJSON(t, doc), operations)
+			if tt.problem != (problemType{}) {
+				var patchErr *patchError
+				if !errors.As(err, &patchErr) || patchErr.problem != tt.problem {
+					t.Errorf("jsonPatch(%s) error = %v, want %s", tt.operations, err, tt.problem.Code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("jsonPatch(%s) error = %v", tt.operations, err)
+			}
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("jsonPatch(%s) = %v, want %v", tt.operations, got, want)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	original := BookRequest{Name: "Solaris", Author: "Stanisław Lem"}
+	tests := []struct {
+		name, contentType, body string
+		want                    BookRequest
+		problem                 problemType
+		// field of validation problem
+		field string
+	}{
+		{"merge patch", mergePatchType, `{"Name":"Eden"}`, BookRequest{Name: "Eden", Author: "Stanisław Lem"}, problemType{}, ""},
+		{"merge patch null", mergePatchType + "; charset=utf-8", `{"Author":null}`, BookRequest{Name: "Solaris"}, problemType{}, ""},
+		{"json patch", jsonPatchType, `[{"op":"test","path":"/Name","value":"Solaris"},{"op":"replace","path":"/Author","value":"Lem"}]`, BookRequest{Name: "Solaris", Author: "Lem"}, problemType{}, ""},
+		{"json patch move", jsonPatchType, `[{"op":"move","from":"/Author","path":"/Name"}]`, BookRequest{Name: "Stanisław Lem"}, problemType{}, ""},
+
+		{"unsupported media type", "application/json", `{"Name":"Eden"}`, BookRequest{}, problemUnsupportedMediaType, ""},
+		{"missing media type", "", `{"Name":"Eden"}`, BookRequest{}, problemUnsupportedMediaType, ""},
+		{"invalid merge patch", mergePatchType, `{"Name":`, BookRequest{}, problemInvalidJSON, ""},
+		{"json patch not array", jsonPatchType, `{"op":"remove","path":"/Name"}`, BookRequest{}, problemInvalidJSON, ""},
+		{"failing test op", jsonPatchType, `[{"op":"replace","path":"/Name","value":"Eden"},{"op":"test","path":"/Author","value":"Lem"}]`, BookRequest{}, problemPatchConflict, ""},
+		{"unknown field", mergePatchType, `{"Isbn":"123"}`, BookRequest{}, problemValidation, "Isbn"},
+		{"type mismatch", mergePatchType, `{"Name":1}`, BookRequest{}, problemValidation, "Name"},
+		{"not object", jsonPatchType, `[{"op":"replace","path":"","value":"Eden"}]`, BookRequest{}, problemValidation, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched BookRequest
+			err := applyPatch(tt.contentType, []byte(tt.body), original, &patched)
+			if tt.problem != (problemType{}) {
+				var patchErr *patchError
+				if !errors.As(err, &patchErr) || patchErr.problem != tt.problem {
+					t.Fatalf("applyPatch(%s) error = %v, want %s", tt.body, err, tt.problem.Code)
+				}
+				if tt.field != "" && (len(patchErr.errs) != 1 || patchErr.errs[0].Field != tt.field) {
+					t.Errorf("applyPatch(%s) field errors = %v, want one of %s", tt.body, patchErr.errs, tt.field)
+				}
+				// patches failing before decoding leave patched untouched
+				if tt.problem != problemValidation && patched != (BookRequest{}) {
+					t.Errorf("applyPatch(%s) changed patched to %v", tt.body, patched)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPatch(%s) error = %v", tt.body, err)
+			}
+			if patched != tt.want {
+				t.Errorf("applyPatch(%s) = %v, want %v", tt.body, patched, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchWritable(t *testing.T) {
+	original := LibraryPatch{LibraryRequest{Date: "2023-01-02T10:00:00Z", Active: true}, BookResponse{Id: 1}, ClientResponse{Id: 2}}
+	tests := []struct {
+		name, contentType, body string
+		want                    LibraryPatch
+		// fields of errors, want is ignored when there are any
+		errs []string
+	}{
+		{"merge patch", mergePatchType, `{"Library":{"Active":false},"Book":{"Id":3}}`, LibraryPatch{LibraryRequest{Date: "2023-01-02T10:00:00Z"}, BookResponse{Id: 3}, ClientResponse{Id: 2}}, nil},
+		{"json patch", jsonPatchType, `[{"op":"test","path":"/Client/Id","value":2},{"op":"replace","path":"/Client","value":{"Id":4}}]`, LibraryPatch{LibraryRequest{Date: "2023-01-02T10:00:00Z", Active: true}, BookResponse{Id: 1}, ClientResponse{Id: 4}}, nil},
+
+		{"merge patch of book name", mergePatchType, `{"Book":{"Id":3,"Name":"Eden"}}`, LibraryPatch{}, []string{"Book.Name"}},
+		{"merge patch of unknown fields", mergePatchType, `{"Isbn":"123","Library":{"DueDate":"2023-02-01"}}`, LibraryPatch{}, []string{"Isbn", "Library.DueDate"}},
+		{"json patch of client name", jsonPatchType, `[{"op":"add","path":"/Client/Name","value":"Ann"}]`, LibraryPatch{}, []string{"Client.Name"}},
+		{"json patch of book object", jsonPatchType, `[{"op":"replace","path":"/Book","value":{"Id":3,"Author":"Lem"}}]`, LibraryPatch{}, []string{"Book.Author"}},
+		{"json patch move", jsonPatchType, `[{"op":"move","from":"/Book/Id","path":"/Book/Version"}]`, LibraryPatch{}, []string{"Book.Version"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched LibraryPatch
+			err := applyPatch(tt.contentType, []byte(tt.body), original, &patched, libraryPatchPaths...)
+			if tt.errs != nil {
+				var patchErr *patchError
+				if !errors.As(err, &patchErr) || patchErr.problem != problemValidation {
+					t.Fatalf("applyPatch(%s) error = %v, want %s", tt.body, err, problemValidation.Code)
+				}
+				var fields []string
+				for _, fieldErr := range patchErr.errs {
+					if fieldErr.Code != fieldInvalid {
+						t.Errorf("applyPatch(%s) %s code = %s, want %s", tt.body, fieldErr.Field, fieldErr.Code, fieldInvalid)
+					}
+					fields = append(fields, fieldErr.Field)
+				}
+				if !reflect.DeepEqual(fields, tt.errs) {
+					t.Errorf("applyPatch(%s) fields = %v, want %v", tt.body, fields, tt.errs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPatch(%s) error = %v", tt.body, err)
+			}
+			if patched != tt.want {
+				t.Errorf("applyPatch(%s) = %v, want %v", tt.body, patched, tt.want)
+			}
+		})
+	}
+}
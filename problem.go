@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Problem is error response body, RFC 7807 problem details (application/problem+json).
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// id from X-Request-ID, helps to find the request in logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// FieldError tells what is wrong with one field of request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// problemType is an entry of error code catalog. Codes are stable, clients may rely on them.
+type problemType struct {
+	Code   string
+	Status int
+	Title  string
+}
+
+var (
+	problemInvalidID            = problemType{"invalid_id", http.StatusBadRequest, "Invalid id"}
+	problemInvalidParameter     = problemType{"invalid_parameter", http.StatusBadRequest, "Invalid query parameter"}
+	problemInvalidJSON          = problemType{"invalid_json", http.StatusBadRequest, "Request body is not valid JSON"}
+	problemValidation           = problemType{"validation_failed", http.StatusBadRequest, "Request body is invalid"}
+	problemInvalidPatch         = problemType{"invalid_patch", http.StatusBadRequest, "Patch document is invalid"}
+	problemNotFound             = problemType{"not_found", http.StatusNotFound, "Resource not found"}
+	problemRouteNotFound        = problemType{"route_not_found", http.StatusNotFound, "No such endpoint"}
+	problemMethodNotAllowed     = problemType{"method_not_allowed", http.StatusMethodNotAllowed, "Method not allowed"}
+	problemPatchConflict        = problemType{"patch_conflict", http.StatusConflict, "Patch can not be applied"}
+	problemBookOnLoan           = problemType{"book_on_loan", http.StatusConflict, "Book is on loan"}
+	problemLoanReturned         = problemType{"loan_returned", http.StatusConflict, "Loan is returned"}
+	problemRenewalLimit         = problemType{"renewal_limit", http.StatusConflict, "Loan can not be renewed again"}
+	problemBookOnHold           = problemType{"book_on_hold", http.StatusConflict, "Book is held for another client"}
+	problemHoldExists           = problemType{"hold_exists", http.StatusConflict, "Client waits for the book already"}
+	problemBookAvailable        = problemType{"book_available", http.StatusConflict, "Book is available"}
+	problemHoldClosed           = problemType{"hold_closed", http.StatusConflict, "Hold is closed"}
+	problemOverBalance          = problemType{"amount_exceeds_balance", http.StatusConflict, "Amount exceeds balance"}
+	problemPreconditionFailed   = problemType{"precondition_failed", http.StatusPreconditionFailed, "Resource was changed"}
+	problemUnsupportedMediaType = problemType{"unsupported_media_type", http.StatusUnsupportedMediaType, "Unsupported Content-Type"}
+	problemPreconditionRequired = problemType{"precondition_required", http.StatusPreconditionRequired, "If-Match is required"}
+	problemInternal             = problemType{"internal_error", http.StatusInternalServerError, "Internal server error"}
+)
+
+// Field error codes
+const (
+	fieldRequired = "required"
+	fieldTooLong  = "too_long"
+	fieldInvalid  = "invalid"
+	fieldUnknown  = "unknown"
+)
+
+// writeProblem sends problem of type t. Details of internal errors are not sent, they are logged by handlers.
+func writeProblem(w http.ResponseWriter, r *http.Request, t problemType, detail string, fieldErrors ...FieldError) {
+	problem := Problem{
+		Type:      "/problems/" + t.Code,
+		Title:     t.Title,
+		Status:    t.Status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      t.Code,
+		Errors:    fieldErrors,
+		RequestID: requestID(r.Context()),
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(t.Status)
+	errEncode := json.NewEncoder(w).Encode(problem)
+	if errEncode != nil {
+		slog.ErrorContext(r.Context(), r.Method+" "+r.URL.Path+" "+errEncode.Error())
+	}
+}
+
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, fieldErrors []FieldError) {
+	writeProblem(w, r, problemValidation, strconv.Itoa(len(fieldErrors))+" invalid fields", fieldErrors...)
+}
+
+// router fallbacks
+
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemRouteNotFound, r.Method+" "+r.URL.Path+" does not exist")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemMethodNotAllowed, r.Method+" is not supported by "+r.URL.Path)
+}
+
+// validation
+
+func required(field, value string) []FieldError {
+	if value == "" {
+		return []FieldError{{Field: field, Code: fieldRequired, Message: field + " is required"}}
+	}
+	return nil
+}
+
+// maxLength mirrors varchar sizes of the schema.
+func maxLength(field, value string, max int) []FieldError {
+	if len([]rune(value)) > max {
+		return []FieldError{{Field: field, Code: fieldTooLong, Message: field + " must be at most " + strconv.Itoa(max) + " characters"}}
+	}
+	return nil
+}
+
+func requiredId(field string, id int) []FieldError {
+	if id == 0 {
+		return []FieldError{{Field: field, Code: fieldRequired, Message: field + " is required"}}
+	}
+	if id < 0 {
+		return []FieldError{{Field: field, Code: fieldInvalid, Message: field + " must be positive"}}
+	}
+	return nil
+}
+
+func validateBook(book BookRequest) []FieldError {
+	var errs []FieldError
+	errs = append(errs, required("Name", book.Name)...)
+	errs = append(errs, maxLength("Name", book.Name, 50)...)
+	errs = append(errs, required("Author", book.Author)...)
+	errs = append(errs, maxLength("Author", book.Author, 50)...)
+	return errs
+}
+
+func validateClient(client ClientRequest) []FieldError {
+	var errs []FieldError
+	errs = append(errs, required("Name", client.Name)...)
+	errs = append(errs, maxLength("Name", client.Name, 100)...)
+	return errs
+}
+
+// referenceProblem turns ErrUnknownBook and ErrUnknownClient into field errors, false for other errors.
+func referenceProblem(w http.ResponseWriter, r *http.Request, err error, library LibraryRequestJoin) bool {
+	switch {
+	case errors.Is(err, ErrUnknownBook):
+		writeValidationProblem(w, r, []FieldError{{Field: "Book.Id", Code: fieldUnknown, Message: "book " + strconv.Itoa(library.Book.Id) + " does not exist"}})
+	case errors.Is(err, ErrUnknownClient):
+		writeValidationProblem(w, r, []FieldError{{Field: "Client.Id", Code: fieldUnknown, Message: "client " + strconv.Itoa(library.Client.Id) + " does not exist"}})
+	default:
+		return false
+	}
+	return true
+}
+
+// validateLibrary checks borrow, dateRequired on updates.
+func validateLibrary(library LibraryRequestJoin, dateRequired bool) []FieldError {
+	var errs []FieldError
+	errs = append(errs, requiredId("Book.Id", library.Book.Id)...)
+	errs = append(errs, requiredId("Client.Id", library.Client.Id)...)
+	if dateRequired {
+		errs = append(errs, required("Library.Date", library.Library.Date)...)
+	}
+	return errs
+}
+
+// validDate accepts dates in formats of filters, empty date is left to required.
+func validDate(field, value string) []FieldError {
+	if _, err := parseDate(value); value != "" && err != nil {
+		return []FieldError{{Field: field, Code: fieldInvalid, Message: field + " " + kindMessages[kindDate]}}
+	}
+	return nil
+}
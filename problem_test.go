@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fieldCodes lists field:code of errors.
+func fieldCodes(errs []FieldError) []string {
+	var codes []string
+	for _, err := range errs {
+		codes = append(codes, err.Field+":"+err.Code)
+	}
+	return codes
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []FieldError
+		want []string
+	}{
+		{"book", validateBook(BookRequest{Name: "Solaris", Author: "Lem"}), nil},
+		{"book without fields", validateBook(BookRequest{}), []string{"Name:required", "Author:required"}},
+		{"book with long name", validateBook(BookRequest{Name: strings.Repeat("ą", 51), Author: "Lem"}), []string{"Name:too_long"}},
+		{"client", validateClient(ClientRequest{Name: strings.Repeat("ą", 100)}), nil},
+		{"client with long name", validateClient(ClientRequest{Name: strings.Repeat("ą", 101)}), []string{"Name:too_long"}},
+		{"loan", validateLibrary(LibraryRequestJoin{Book: Book{Id: 1}, Client: Client{Id: 2}}, false), nil},
+		{"loan without ids", validateLibrary(LibraryRequestJoin{Client: Client{Id: -1}}, false), []string{"Book.Id:required", "Client.Id:invalid"}},
+		{"loan without date", validateLibrary(LibraryRequestJoin{Book: Book{Id: 1}, Client: Client{Id: 2}}, true), []string{"Library.Date:required"}},
+	}
+	for _, test := range tests {
+		if got := fieldCodes(test.errs); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: errors %v, want %v", test.name, got, test.want)
+		}
+	}
+}
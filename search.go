@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxSearchTerms limits words of search query.
+const maxSearchTerms = 10
+
+// minWordLength is innodb_ft_min_token_size of MySQL, shorter words are not indexed.
+const minWordLength = 3
+
+// stopwords of InnoDB (innodb_ft_default_stopword) long enough to be indexed,
+// MySQL ignores them in books and queries.
+var stopwords = map[string]bool{
+	"about": true, "are": true, "com": true, "for": true, "from": true, "how": true,
+	"that": true, "the": true, "this": true, "was": true, "what": true, "when": true,
+	"where": true, "who": true, "will": true, "with": true, "und": true, "www": true,
+}
+
+// searchable tells if folded word is indexed by MySQL FULLTEXT with default
+// settings, searchIndex skips the other words too so all backends find the same books.
+func searchable(word string) bool {
+	return utf8.RuneCountInString(word) >= minWordLength && !stopwords[word]
+}
+
+// SearchQuery finds books having words starting with every of Terms (folded) in Name or Author.
+type SearchQuery struct {
+	Terms  []string
+	Limit  int
+	Offset int
+}
+
+type SearchHit struct {
+	Book  Book
+	Score float64
+}
+
+type SearchResult struct {
+	Book  Book
+	Score float64
+	// fields with matched words marked <mark>...</mark>, HTML escaped
+	Highlights map[string]string `json:",omitempty"`
+}
+
+// letters without decomposition, the rest loses diacritics in NFD
+var foldReplacer = strings.NewReplacer("ł", "l", "Ł", "l", "đ", "d", "Đ", "d", "ø", "o", "Ø", "o", "ß", "ss")
+
+// fold makes text lowercase without diacritics, "Żółw" is "zolw".
+func fold(text string) string {
+	text = foldReplacer.Replace(text)
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		folded = text
+	}
+	return strings.ToLower(folded)
+}
+
+// token is a word of text, Start and End are byte offsets in the original text.
+type token struct {
+	Word       string
+	Start, End int
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			tokens = append(tokens, token{fold(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{fold(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// searchTerms returns folded searchable words of query, duplicates and words
+// being prefixes of other words are dropped as they match the same books.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, t := range tokenize(query) {
+		if searchable(t.Word) {
+			terms = append(terms, t.Word)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	var unique []string
+	for _, term := range terms {
+		redundant := false
+		for _, other := range unique {
+			redundant = redundant || strings.HasPrefix(other, term)
+		}
+		if !redundant {
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// searchText is indexed text of book, folded words of name and author.
+func searchText(book BookRequest) string {
+	var words []string
+	for _, t := range tokenize(book.Name + " " + book.Author) {
+		words = append(words, t.Word)
+	}
+	return strings.Join(words, " ")
+}
+
+// highlight marks words of text starting with any of terms.
+func highlight(text string, terms []string) (string, bool) {
+	var b strings.Builder
+	var last int
+	for _, t := range tokenize(text) {
+		if !searchable(t.Word) {
+			continue
+		}
+		for _, term := range terms {
+			if strings.HasPrefix(t.Word, term) {
+				b.WriteString(html.EscapeString(text[last:t.Start]))
+				b.WriteString("<mark>" + html.EscapeString(text[t.Start:t.End]) + "</mark>")
+				last = t.End
+				break
+			}
+		}
+	}
+	if last == 0 {
+		return "", false
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), true
+}
+
+// Fields of book scored by search, matches in name weigh more.
+const (
+	fieldName = 1 << iota
+	fieldAuthor
+)
+
+// searchIndex is in-process inverted index of books for backends without
+// full-text search. Words are looked up by prefix in sorted list of words.
+type searchIndex struct {
+	mu       sync.RWMutex
+	books    map[int]Book
+	postings map[string]map[int]int // word -> book id -> fields having the word
+	words    []string               // sorted keys of postings
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{books: make(map[int]Book), postings: make(map[string]map[int]int)}
+}
+
+// Put adds book or replaces its previous version.
+func (x *searchIndex) Put(book Book) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(book.Id)
+	x.books[book.Id] = book
+	for _, field := range []struct {
+		text string
+		flag int
+	}{{book.Name, fieldName}, {book.Author, fieldAuthor}} {
+		for _, t := range tokenize(field.text) {
+			if !searchable(t.Word) {
+				continue
+			}
+			docs, ok := x.postings[t.Word]
+			if !ok {
+				docs = make(map[int]int)
+				x.postings[t.Word] = docs
+				i := sort.SearchStrings(x.words, t.Word)
+				x.words = append(x.words, "")
+				copy(x.words[i+1:], x.words[i:])
+				x.words[i] = t.Word
+			}
+			docs[book.Id] |= field.flag
+		}
+	}
+}
+
+func (x *searchIndex) Remove(id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+func (x *searchIndex) remove(id int) {
+	book, ok := x.books[id]
+	if !ok {
+		return
+	}
+	delete(x.books, id)
+	for _, t := range tokenize(book.Name + " " + book.Author) {
+		docs, ok := x.postings[t.Word]
+		if !ok {
+			continue
+		}
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(x.postings, t.Word)
+			if i := sort.SearchStrings(x.words, t.Word); i < len(x.words) && x.words[i] == t.Word {
+				x.words = append(x.words[:i], x.words[i+1:]...)
+			}
+		}
+	}
+}
+
+// Search returns page of books matching all terms, best first, and number of all matching books.
+// Book scores idf of every term, times 2 when word is whole term and times 1.5 when it is in name.
+func (x *searchIndex) Search(q SearchQuery) ([]SearchHit, int) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var scores map[int]float64
+	for _, term := range q.Terms {
+		best := make(map[int]float64)
+		for i := sort.SearchStrings(x.words, term); i < len(x.words) && strings.HasPrefix(x.words[i], term); i++ {
+			weight := 1.0
+			if x.words[i] == term {
+				weight = 2
+			}
+			for id, fields := range x.postings[x.words[i]] {
+				score := weight
+				if fields&fieldName != 0 {
+					score *= 1.5
+				}
+				best[id] = math.Max(best[id], score)
+			}
+		}
+		idf := math.Log(1 + float64(len(x.books))/float64(max(len(best), 1)))
+		if scores == nil {
+			scores = make(map[int]float64, len(best))
+			for id, score := range best {
+				scores[id] = score * idf
+			}
+			continue
+		}
+		for id := range scores {
+			if score, ok := best[id]; ok {
+				scores[id] += score * idf
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, SearchHit{Book: x.books[id], Score: math.Round(score*1000) / 1000})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Book.Id < hits[j].Book.Id
+	})
+	total := len(hits)
+	if q.Offset >= len(hits) {
+		return nil, total
+	}
+	hits = hits[q.Offset:]
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, total
+}
+
+// GET /api/search?q=lem solaris
+func getSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := SearchQuery{Terms: searchTerms(query.Get("q")), Limit: config.Pagination.DefaultLimit}
+	var errs []FieldError
+	if len(search.Terms) == 0 {
+		errs = append(errs, FieldError{Field: "q", Code: fieldRequired, Message: "q must have at least one word of " + strconv.Itoa(minWordLength) + " or more letters, not a stopword"})
+	} else if len(search.Terms) > maxSearchTerms {
+		errs = append(errs, FieldError{Field: "q", Code: fieldTooLong, Message: "q must have at most " + strconv.Itoa(maxSearchTerms) + " words"})
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > config.Pagination.MaxLimit {
+			errs = append(errs, FieldError{Field: "limit", Code: fieldInvalid, Message: "limit must be a number from 1 to " + strconv.Itoa(config.Pagination.MaxLimit)})
+		}
+		search.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			errs = append(errs, FieldError{Field: "offset", Code: fieldInvalid, Message: "offset must be a number, at least 0"})
+		}
+		search.Offset = offset
+	}
+	if len(errs) > 0 {
+		writeProblem(w, r, problemInvalidParameter, strconv.Itoa(len(errs))+" invalid parameters", errs...)
+		slog.WarnContext(r.Context(), "GET /api/search wrong query "+r.URL.RawQuery)
+		return
+	}
+
+	// repository
+	hits, total, errStore := store.SearchBooks(r.Context(), search)
+	if errStore != nil {
+		writeProblem(w, r, problemInternal, "")
+		slog.ErrorContext(r.Context(), "GET /api/search "+errStore.Error())
+		return
+	}
+
+	response := PageResponse[SearchResult]{Items: make([]SearchResult, 0, len(hits)), Total: total}
+	for _, hit := range hits {
+		result := SearchResult{Book: hit.Book, Score: hit.Score, Highlights: make(map[string]string)}
+		if marked, ok := highlight(hit.Book.Name, search.Terms); ok {
+			result.Highlights["Name"] = marked
+		}
+		if marked, ok := highlight(hit.Book.Author, search.Terms); ok {
+			result.Highlights["Author"] = marked
+		}
+		response.Items = append(response.Items, result)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET /api/search "+errEncode.Error())
+		return
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Żółw", "zolw"},
+		{"Łódź", "lodz"},
+		{"Straße", "strasse"},
+		{"SOLARIS", "solaris"},
+	}
+	for _, tt := range tests {
+		if got := fold(tt.text); got != tt.want {
+			t.Errorf("fold(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Solaris", []string{"solaris"}},
+		{"sol solaris SOLARIS", []string{"solaris"}},
+		{"żółw, lem!", []string{"zolw", "lem"}},
+		{"the solaris of lem", []string{"solaris", "lem"}},
+		{"ab c", nil},
+		{"with about", nil},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func testSearchIndex() *searchIndex {
+	index := newSearchIndex()
+	for _, book := range []Book{
+		{Id: 1, Name: "Solaris", Author: "Stanisław Lem"},
+		{Id: 2, Name: "Lemur", Author: "Anna Nowak"},
+		{Id: 3, Name: "Żółw", Author: "Jan Kowalski"},
+		{Id: 4, Name: "The Cyberiad", Author: "Stanisław Lem"},
+		{Id: 5, Name: "Overkill", Author: "Lemmy Kilmister"},
+	} {
+		index.Put(book)
+	}
+	return index
+}
+
+func hitIds(hits []SearchHit) []int {
+	var ids []int
+	for _, hit := range hits {
+		ids = append(ids, hit.Book.Id)
+	}
+	return ids
+}
+
+func TestSearchIndexSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		limit     int
+		offset    int
+		want      []int
+		wantTotal int
+	}{
+		// whole word ranks above prefix, prefix in name above prefix in author, ties by id
+		{"ranking", "lem", 10, 0, []int{1, 4, 2, 5}, 4},
+		{"folding", "zolw", 10, 0, []int{3}, 1},
+		{"folded letter", "stanislaw", 10, 0, []int{1, 4}, 2},
+		{"all terms", "lem cyber", 10, 0, []int{4}, 1},
+		{"no match", "lem zolw", 10, 0, nil, 0},
+		{"limit", "lem", 2, 0, []int{1, 4}, 4},
+		{"offset", "lem", 2, 3, []int{5}, 4},
+		{"offset past end", "lem", 2, 4, nil, 4},
+	}
+	index := testSearchIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, total := index.Search(SearchQuery{Terms: searchTerms(tt.query), Limit: tt.limit, Offset: tt.offset})
+			if got := hitIds(hits); !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("Search(%q) = %v, %d, want %v, %d", tt.query, got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSearchIndexSkipsStopwords(t *testing.T) {
+	// "The" of "The Cyberiad" is not indexed, like in MySQL
+	if hits, _ := testSearchIndex().Search(SearchQuery{Terms: []string{"the"}, Limit: 10}); len(hits) != 0 {
+		t.Errorf("Search(the) = %v, want none", hitIds(hits))
+	}
+}
+
+func TestSearchIndexScores(t *testing.T) {
+	hits, _ := testSearchIndex().Search(SearchQuery{Terms: []string{"lem"}, Limit: 10})
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Errorf("hit %d scores %v, more than hit %d with %v", hits[i].Book.Id, hits[i].Score, hits[i-1].Book.Id, hits[i-1].Score)
+		}
+	}
+	if hits[0].Score == hits[len(hits)-1].Score {
+		t.Errorf("whole word scores %v, the same as prefix in author", hits[0].Score)
+	}
+}
+
+func TestSearchIndexPutRemove(t *testing.T) {
+	index := testSearchIndex()
+	index.Put(Book{Id: 3, Name: "Solaris", Author: "Anonim"})
+	if hits, _ := index.Search(SearchQuery{Terms: []string{"zolw"}, Limit: 10}); len(hits) != 0 {
+		t.Errorf("replaced book found by old name: %v", hitIds(hits))
+	}
+	if hits, _ := index.Search(SearchQuery{Terms: []string{"solaris"}, Limit: 10}); !reflect.DeepEqual(hitIds(hits), []int{1, 3}) {
+		t.Errorf("Search(solaris) = %v, want [1 3]", hitIds(hits))
+	}
+	index.Remove(1)
+	index.Remove(1)
+	if hits, _ := index.Search(SearchQuery{Terms: []string{"solaris"}, Limit: 10}); !reflect.DeepEqual(hitIds(hits), []int{3}) {
+		t.Errorf("Search(solaris) after Remove(1) = %v, want [3]", hitIds(hits))
+	}
+	for _, word := range index.words {
+		if len(index.postings[word]) == 0 {
+			t.Errorf("word %q left in index without books", word)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text   string
+		terms  []string
+		want   string
+		wantOk bool
+	}{
+		{"Żółw", []string{"zolw"}, "<mark>Żółw</mark>", true},
+		{"Stanisław Lem", []string{"stan", "lem"}, "<mark>Stanisław</mark> <mark>Lem</mark>", true},
+		{"Tom & <Jerry>", []string{"jer"}, "Tom &amp; &lt;<mark>Jerry</mark>&gt;", true},
+		{"The Theory", []string{"the"}, "The <mark>Theory</mark>", true},
+		{"Solaris", []string{"lem"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := highlight(tt.text, tt.terms)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("highlight(%q, %q) = %q, %v, want %q, %v", tt.text, tt.terms, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		for _, book := range []string{
+			`{"Name":"Solaris","Author":"Stanisław Lem"}`,
+			`{"Name":"Lemur","Author":"Anna Nowak"}`,
+			`{"Name":"Żółw","Author":"Jan Kowalski"}`,
+		} {
+			expect(t, serve(h, "POST", "/api/books", book), http.StatusCreated, "")
+		}
+		expect(t, serve(h, "PUT", "/api/books/2", `{"Name":"Lemur","Author":"Stanisław Lem"}`, "If-Match", `"1"`), http.StatusOK, "")
+		expect(t, serve(h, "DELETE", "/api/books/1", "", "If-Match", `"1"`), http.StatusNoContent, "")
+
+		w := serve(h, "GET", "/api/search?q=stanislaw", "")
+		expect(t, w, http.StatusOK, "")
+		page := decodeBody[PageResponse[SearchResult]](t, w)
+		if page.Total != 1 || len(page.Items) != 1 || page.Items[0].Book.Id != 2 {
+			t.Fatalf("GET /api/search?q=stanislaw = %+v", page)
+		}
+		if marked := page.Items[0].Highlights["Author"]; marked != "<mark>Stanisław</mark> Lem" {
+			t.Errorf("highlighted author %q", marked)
+		}
+
+		w = serve(h, "GET", "/api/search?q=zolw", "")
+		if page := decodeBody[PageResponse[SearchResult]](t, w); page.Total != 1 || page.Items[0].Highlights["Name"] != "<mark>Żółw</mark>" {
+			t.Errorf("GET /api/search?q=zolw = %+v", page)
+		}
+		expect(t, serve(h, "GET", "/api/search?q=", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+		expect(t, serve(h, "GET", "/api/search?q=lem&limit=0", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+	})
+}
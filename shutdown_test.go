@@ -0,0 +1,36 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownOnSignal(t *testing.T) {
+	config = defaultConfig()
+	config.Server.Listen = "127.0.0.1:0"
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	errServe := make(chan error, 1)
+	go func() {
+		errServe <- handleRequests()
+	}()
+	// give handleRequests time to subscribe to signals, SIGTERM would kill the test otherwise
+	time.Sleep(100 * time.Millisecond)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errServe:
+		if err != nil {
+			t.Errorf("handleRequests = %v, want nil after SIGTERM", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down after SIGTERM")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by stores when the requested (or updated, deleted) row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrUnknownBook and ErrUnknownClient are returned when borrow refers to missing book or client.
+	ErrUnknownBook   = errors.New("book does not exist")
+	ErrUnknownClient = errors.New("client does not exist")
+	// ErrVersionConflict is returned when updated or deleted row has other version than expected,
+	// it was changed by another request.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrBookOnLoan is returned when checked out book has an active loan already.
+	ErrBookOnLoan = errors.New("book is on loan")
+	// ErrLoanReturned is returned when returned or renewed loan is not active.
+	ErrLoanReturned = errors.New("loan is returned")
+	// ErrRenewalLimit is returned when renewed loan was renewed maximum times already.
+	ErrRenewalLimit = errors.New("renewal limit reached")
+	// ErrBookOnHold is returned when book is checked out or renewed while other client is first in its queue.
+	ErrBookOnHold = errors.New("book is on hold")
+	// ErrHoldExists is returned when client queues for a book twice.
+	ErrHoldExists = errors.New("hold exists")
+	// ErrBookAvailable is returned when client queues for a book which can be checked out.
+	ErrBookAvailable = errors.New("book is available")
+	// ErrHoldClosed is returned when cancelled hold is not waiting nor ready.
+	ErrHoldClosed = errors.New("hold is closed")
+	// ErrUnknownLoan is returned when ledger entry refers to missing loan or loan of other client.
+	ErrUnknownLoan = errors.New("loan does not exist")
+	// ErrOverBalance is returned when payment or waiver is larger than balance of client.
+	ErrOverBalance = errors.New("amount exceeds balance")
+)
+
+// ListQuery selects page of collection: items matching all Filters ordered by
+// Sort, at most Limit of them following After (cursor) or first Offset items.
+type ListQuery struct {
+	Filters []Filter
+	// always ends with id, so the order is unique
+	Sort  []Sort
+	Limit int
+	// sort values of the last item of previous page
+	After  []any
+	Offset int
+}
+
+// Filter compares field with value, Op is one of op* constants.
+type Filter struct {
+	Field string
+	Op    string
+	Value any
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// BookStore persists books (table book).
+type BookStore interface {
+	GetBook(ctx context.Context, id int) (Book, error)
+	// GetBooks returns page of books and number of all books matching filters.
+	GetBooks(ctx context.Context, q ListQuery) ([]Book, int, error)
+	// BookFacets counts books matching filters by values of every facet.
+	BookFacets(ctx context.Context, filters []Filter, facets []Facet) (map[string][]FacetCount, error)
+	CreateBook(ctx context.Context, book BookRequest) (int, error)
+	// UpdateBook and DeleteBook change the book only when it has the given version,
+	// update increments it.
+	UpdateBook(ctx context.Context, id, version int, book BookRequest) error
+	DeleteBook(ctx context.Context, id, version int) error
+	// SearchBooks returns page of books matching search query, best first, and number of all matching books.
+	SearchBooks(ctx context.Context, q SearchQuery) ([]SearchHit, int, error)
+}
+
+// SearchIndexer is implemented by stores keeping search index in process, it is built on start.
+type SearchIndexer interface {
+	BuildSearchIndex(ctx context.Context) error
+}
+
+// ClientStore persists clients (table client).
+type ClientStore interface {
+	GetClient(ctx context.Context, id int) (Client, error)
+	GetClients(ctx context.Context, q ListQuery) ([]Client, int, error)
+	CreateClient(ctx context.Context, client ClientRequest) (int, error)
+	UpdateClient(ctx context.Context, id, version int, client ClientRequest) error
+	DeleteClient(ctx context.Context, id, version int) error
+}
+
+// LoanStore persists borrowed books (table library) joined with their book and client.
+type LoanStore interface {
+	GetLoan(ctx context.Context, id int) (LibraryJoin, error)
+	GetLoans(ctx context.Context, q ListQuery) ([]LibraryJoin, int, error)
+	// CreateLoan and Checkout make loans dated now and due back at due. Active
+	// loan of a book with an active loan fails with ErrBookOnLoan.
+	CreateLoan(ctx context.Context, loan LibraryRequestJoin, due time.Time) (int, error)
+	// UpdateLoan stores Library.Date, one of dateLayouts, in format of the other dates.
+	// It checks active loans of the book like CreateLoan, loan made inactive is
+	// returned like by ReturnLoan.
+	UpdateLoan(ctx context.Context, id, version int, loan LibraryRequestJoin, pickup time.Duration, fines FinesConfig) error
+	DeleteLoan(ctx context.Context, id, version int) error
+	// Checkout lends the book to the client, unless it has an active loan, and returns the new loan.
+	Checkout(ctx context.Context, bookId, clientId int, due time.Time) (LibraryJoin, error)
+	// ReturnLoan makes active loan inactive and stamps its return time. Late return
+	// charges the client by fines, the first hold of the book becomes ready for pickup period.
+	ReturnLoan(ctx context.Context, id int, pickup time.Duration, fines FinesConfig) (LibraryJoin, error)
+	// RenewLoan moves due date of active loan by period and records it in history,
+	// unless the loan was renewed maxRenewals times or other client holds the book.
+	RenewLoan(ctx context.Context, id int, period time.Duration, maxRenewals int) (LibraryJoin, error)
+	// GetRenewals returns renewal history of loan, the oldest first.
+	GetRenewals(ctx context.Context, id int) ([]Renewal, error)
+	// LoanStats counts active loans, overdue are those due before now.
+	LoanStats(ctx context.Context, now time.Time) (LoanStats, error)
+}
+
+// HoldStore persists queues of clients waiting for books (table hold). Holds of
+// a book are served in order of ids, checkout of the first one fulfills it.
+type HoldStore interface {
+	// PlaceHold queues client for book on loan.
+	PlaceHold(ctx context.Context, bookId, clientId int) (Hold, error)
+	// BookHolds returns waiting and ready holds of book in queue order.
+	BookHolds(ctx context.Context, bookId int) ([]Hold, error)
+	// ClientHolds returns all holds of client, the oldest first.
+	ClientHolds(ctx context.Context, clientId int) ([]Hold, error)
+	// CancelHold cancels waiting or ready hold of client, the next one may become ready.
+	CancelHold(ctx context.Context, clientId, id int, pickup time.Duration) error
+	// UpdateHolds expires ready holds not picked up in time and makes the next
+	// holds of books which are not on loan ready for pickup period.
+	UpdateHolds(ctx context.Context, pickup time.Duration) error
+}
+
+// LedgerStore persists fees, payments and waivers of clients (table ledger).
+type LedgerStore interface {
+	// GetLedger returns entries of client, the oldest first.
+	GetLedger(ctx context.Context, clientId int) ([]LedgerEntry, error)
+	// AddLedgerEntry records entry of client dated now and returns it. Payments and
+	// waivers can not exceed balance of the client.
+	AddLedgerEntry(ctx context.Context, clientId int, entry LedgerEntry) (LedgerEntry, error)
+}
+
+type LoanStats struct {
+	Active  int
+	Overdue int
+}
+
+// Store is the storage backend used by the handlers.
+type Store interface {
+	BookStore
+	ClientStore
+	LoanStore
+	HoldStore
+	LedgerStore
+
+	// Ping checks if the backend is reachable.
+	Ping(ctx context.Context) error
+	// Version describes the backend, e.g. the database server version.
+	Version(ctx context.Context) (string, error)
+	Close() error
+}
+
+// openStore creates the storage backend selected in config. For sqlite DSN is
+// path to database file.
+func openStore(config DatabaseConfig) (Store, error) {
+	var s *sqlStore
+	var err error
+	switch config.Driver {
+	case "mysql":
+		s, err = newMySQLStore(config.DSN)
+	case "sqlite":
+		s, err = newSQLiteStore(config.DSN)
+	case "postgres":
+		s, err = newPostgresStore(config.DSN)
+	case "memory":
+		return newMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.SetMaxOpenConns(config.MaxOpenConns)
+	s.db.SetMaxIdleConns(config.MaxIdleConns)
+	s.db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	return s, nil
+}
+
+// waitForStore pings store until it answers, waiting interval between attempts
+// and doubling it up to maxInterval. Gives up after timeout.
+func waitForStore(store Store, timeout, interval, maxInterval time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		err := store.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("database not reachable", "attempt", attempt, "error", err.Error(), "retry_in", interval.String())
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
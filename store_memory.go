@@ -0,0 +1,864 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps data in process memory. It is meant for tests and demos,
+// everything is lost on restart.
+type memoryStore struct {
+	mu sync.RWMutex
+
+	books   map[int]Book
+	clients map[int]Client
+	loans   map[int]memoryLoan
+	holds   map[int]memoryHold
+	ledger  map[int]memoryLedgerEntry
+
+	lastBookId, lastClientId, lastLoanId, lastRenewalId, lastHoldId, lastLedgerId int
+
+	index *searchIndex
+}
+
+// memoryLoan mirrors a row of table library.
+type memoryLoan struct {
+	Library  Library
+	IdBook   int
+	IdClient int
+	// rows of table renewal, deleted with the loan
+	Renewals []Renewal
+}
+
+// memoryHold mirrors a row of table hold, Book and Client of Hold are not set.
+type memoryHold struct {
+	Hold     Hold
+	IdBook   int
+	IdClient int
+}
+
+// memoryLedgerEntry mirrors a row of table ledger, Loan of deleted loan is
+// cleared when read (ON DELETE SET NULL).
+type memoryLedgerEntry struct {
+	Entry    LedgerEntry
+	IdClient int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		books:   make(map[int]Book),
+		clients: make(map[int]Client),
+		loans:   make(map[int]memoryLoan),
+		holds:   make(map[int]memoryHold),
+		ledger:  make(map[int]memoryLedgerEntry),
+		index:   newSearchIndex(),
+	}
+}
+
+func (s *memoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *memoryStore) Version(ctx context.Context) (string, error) {
+	return "memory", nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// now is time of change, seconds like updated_at columns.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// checkVersion mimics UPDATE or DELETE ... WHERE id = ? AND version = ?.
+func checkVersion(version int, ok bool, expected int) error {
+	if !ok {
+		return ErrNotFound
+	}
+	if version != expected {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// sortedIds returns map keys in ascending order, like rows read by primary key.
+func sortedIds[T any](m map[int]T) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// listItems filters, sorts and pages items like listClauses of sqlStore, returns
+// page and number of items matching filters.
+func listItems[T any](items []T, fields []listField[T], q ListQuery) ([]T, int) {
+	var matching []T
+	for _, item := range items {
+		if matches(item, fields, q.Filters) {
+			matching = append(matching, item)
+		}
+	}
+	total := len(matching)
+
+	// compare orders items by q.Sort, values are values of item or cursor
+	compare := func(a, b func(i int, field listField[T]) any) int {
+		for i, s := range q.Sort {
+			field, _ := findField(fields, s.Field)
+			if c := compareValues(a(i, field), b(i, field)); c != 0 {
+				if s.Desc {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	}
+	valuesOf := func(item T) func(int, listField[T]) any {
+		return func(_ int, field listField[T]) any { return field.Value(item) }
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return compare(valuesOf(matching[i]), valuesOf(matching[j])) < 0
+	})
+
+	start := q.Offset
+	if len(q.After) > 0 {
+		after := func(i int, _ listField[T]) any { return q.After[i] }
+		start = sort.Search(len(matching), func(i int) bool { return compare(valuesOf(matching[i]), after) > 0 })
+	}
+	if start > len(matching) {
+		return nil, total
+	}
+	matching = matching[start:]
+	if len(matching) > q.Limit {
+		matching = matching[:q.Limit]
+	}
+	return matching, total
+}
+
+func matches[T any](item T, fields []listField[T], filters []Filter) bool {
+	for _, filter := range filters {
+		field, _ := findField(fields, filter.Field)
+		value := field.Value(item)
+		var ok bool
+		switch filter.Op {
+		case opEqual:
+			ok = compareValues(value, filter.Value) == 0
+		case opContains:
+			ok = strings.Contains(strings.ToLower(value.(string)), strings.ToLower(filter.Value.(string)))
+		case opFrom:
+			ok = compareValues(value, filter.Value) >= 0
+		case opTo:
+			ok = compareValues(value, filter.Value) <= 0
+		case opBefore:
+			ok = compareValues(value, filter.Value) < 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compares values of the same field kind.
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+// Books
+
+func (s *memoryStore) GetBook(ctx context.Context, id int) (Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	book, ok := s.books[id]
+	if !ok {
+		return Book{Id: id}, ErrNotFound
+	}
+	return book, nil
+}
+
+func (s *memoryStore) GetBooks(ctx context.Context, q ListQuery) ([]Book, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var books []Book
+	for _, id := range sortedIds(s.books) {
+		books = append(books, s.books[id])
+	}
+	books, total := listItems(books, s.bookFields(), q)
+	return books, total, nil
+}
+
+// bookFields returns bookFields with values of fields computed from loans, like
+// their columns in sqlStore. Callers hold the lock.
+func (s *memoryStore) bookFields() []listField[Book] {
+	active, lent := make(map[int]bool), make(map[int]bool)
+	for _, loan := range s.loans {
+		lent[loan.IdBook] = true
+		active[loan.IdBook] = active[loan.IdBook] || loan.Library.Active
+	}
+	fields := slices.Clone(bookFields)
+	for i := range fields {
+		switch fields[i].Name {
+		case "available":
+			fields[i].Value = func(book Book) any { return !active[book.Id] }
+		case "lent":
+			fields[i].Value = func(book Book) any { return lent[book.Id] }
+		}
+	}
+	return fields
+}
+
+func (s *memoryStore) BookFacets(ctx context.Context, filters []Filter, facets []Facet) (map[string][]FacetCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fields := s.bookFields()
+	counts := make(map[string][]FacetCount, len(facets))
+	for _, facet := range facets {
+		field, _ := findField(fields, facet.Name)
+		values := make(map[any]int)
+		for _, book := range s.books {
+			if matches(book, fields, filters) {
+				values[field.Value(book)]++
+			}
+		}
+		found := make([]FacetCount, 0, len(values))
+		for value, count := range values {
+			found = append(found, FacetCount{Value: value, Count: count})
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if facet.Limit > 0 && found[i].Count != found[j].Count {
+				return found[i].Count > found[j].Count
+			}
+			return compareValues(found[i].Value, found[j].Value) < 0
+		})
+		if facet.Limit > 0 && len(found) > facet.Limit {
+			found = found[:facet.Limit]
+		}
+		counts[facet.Name] = found
+	}
+	return counts, nil
+}
+
+func (s *memoryStore) CreateBook(ctx context.Context, book BookRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastBookId++
+	s.books[s.lastBookId] = Book{Id: s.lastBookId, Name: book.Name, Author: book.Author, Version: 1, UpdatedAt: now()}
+	s.index.Put(s.books[s.lastBookId])
+	return s.lastBookId, nil
+}
+
+func (s *memoryStore) UpdateBook(ctx context.Context, id, version int, book BookRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.books[id]
+	if err := checkVersion(current.Version, ok, version); err != nil {
+		return err
+	}
+	s.books[id] = Book{Id: id, Name: book.Name, Author: book.Author, Version: version + 1, UpdatedAt: now()}
+	s.index.Put(s.books[id])
+	return nil
+}
+
+func (s *memoryStore) DeleteBook(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.books[id]
+	if err := checkVersion(current.Version, ok, version); err != nil {
+		return err
+	}
+	delete(s.books, id)
+	s.index.Remove(id)
+	// ON DELETE CASCADE
+	for loanId, loan := range s.loans {
+		if loan.IdBook == id {
+			delete(s.loans, loanId)
+		}
+	}
+	for holdId, hold := range s.holds {
+		if hold.IdBook == id {
+			delete(s.holds, holdId)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) SearchBooks(ctx context.Context, q SearchQuery) ([]SearchHit, int, error) {
+	hits, total := s.index.Search(q)
+	return hits, total, nil
+}
+
+// Clients
+
+func (s *memoryStore) GetClient(ctx context.Context, id int) (Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	client, ok := s.clients[id]
+	if !ok {
+		return Client{Id: id}, ErrNotFound
+	}
+	return client, nil
+}
+
+func (s *memoryStore) GetClients(ctx context.Context, q ListQuery) ([]Client, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []Client
+	for _, id := range sortedIds(s.clients) {
+		clients = append(clients, s.clients[id])
+	}
+	clients, total := listItems(clients, clientFields, q)
+	return clients, total, nil
+}
+
+func (s *memoryStore) CreateClient(ctx context.Context, client ClientRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastClientId++
+	s.clients[s.lastClientId] = Client{Id: s.lastClientId, Name: client.Name, Version: 1, UpdatedAt: now()}
+	return s.lastClientId, nil
+}
+
+func (s *memoryStore) UpdateClient(ctx context.Context, id, version int, client ClientRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.clients[id]
+	if err := checkVersion(current.Version, ok, version); err != nil {
+		return err
+	}
+	s.clients[id] = Client{Id: id, Name: client.Name, Version: version + 1, UpdatedAt: now()}
+	return nil
+}
+
+func (s *memoryStore) DeleteClient(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.clients[id]
+	if err := checkVersion(current.Version, ok, version); err != nil {
+		return err
+	}
+	delete(s.clients, id)
+	// ON DELETE CASCADE
+	for loanId, loan := range s.loans {
+		if loan.IdClient == id {
+			delete(s.loans, loanId)
+		}
+	}
+	for holdId, hold := range s.holds {
+		if hold.IdClient == id {
+			delete(s.holds, holdId)
+		}
+	}
+	for entryId, entry := range s.ledger {
+		if entry.IdClient == id {
+			delete(s.ledger, entryId)
+		}
+	}
+	return nil
+}
+
+// Libraries
+
+// join resolves book and client of a loan, like the INNER JOIN in sqlStore.
+func (s *memoryStore) join(loan memoryLoan) (LibraryJoin, bool) {
+	book, okBook := s.books[loan.IdBook]
+	client, okClient := s.clients[loan.IdClient]
+	setOverdue(&loan.Library, time.Now())
+	return LibraryJoin{Library: loan.Library, Book: book, Client: client}, okBook && okClient
+}
+
+// checkForeignKeys mimics FK_Library_Book and FK_Library_Client constraints.
+func (s *memoryStore) checkForeignKeys(loan LibraryRequestJoin) error {
+	if _, ok := s.books[loan.Book.Id]; !ok {
+		return ErrUnknownBook
+	}
+	if _, ok := s.clients[loan.Client.Id]; !ok {
+		return ErrUnknownClient
+	}
+	return nil
+}
+
+func (s *memoryStore) GetLoan(ctx context.Context, id int) (LibraryJoin, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	loan, ok := s.loans[id]
+	if !ok {
+		return LibraryJoin{Library: Library{Id: id}}, ErrNotFound
+	}
+	join, ok := s.join(loan)
+	if !ok {
+		return join, ErrNotFound
+	}
+	return join, nil
+}
+
+func (s *memoryStore) GetLoans(ctx context.Context, q ListQuery) ([]LibraryJoin, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var loans []LibraryJoin
+	for _, id := range sortedIds(s.loans) {
+		if join, ok := s.join(s.loans[id]); ok {
+			loans = append(loans, join)
+		}
+	}
+	loans, total := listItems(loans, loanFields, q)
+	return loans, total, nil
+}
+
+func (s *memoryStore) CreateLoan(ctx context.Context, loan LibraryRequestJoin, due time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkForeignKeys(loan); err != nil {
+		return 0, err
+	}
+	if loan.Library.Active && s.activeLoans(loan.Book.Id, 0) > 0 {
+		return 0, ErrBookOnLoan
+	}
+	s.lastLoanId++
+	s.loans[s.lastLoanId] = memoryLoan{
+		Library:  Library{Id: s.lastLoanId, Date: now().Format(time.RFC3339), DueDate: due.UTC().Format(time.RFC3339), Active: loan.Library.Active, Version: 1, UpdatedAt: now()},
+		IdBook:   loan.Book.Id,
+		IdClient: loan.Client.Id,
+	}
+	return s.lastLoanId, nil
+}
+
+func (s *memoryStore) UpdateLoan(ctx context.Context, id, version int, loan LibraryRequestJoin, pickup time.Duration, fines FinesConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.loans[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := s.checkForeignKeys(loan); err != nil {
+		return err
+	}
+	if err := checkVersion(current.Library.Version, ok, version); err != nil {
+		return err
+	}
+	date, err := parseDate(loan.Library.Date)
+	if err != nil {
+		return err
+	}
+	active := current.Library.Active
+	if loan.Library.Active {
+		others := s.activeLoans(loan.Book.Id, 0)
+		if active && current.IdBook == loan.Book.Id {
+			others--
+		}
+		if others > 0 {
+			return ErrBookOnLoan
+		}
+	}
+	updated := memoryLoan{
+		Library:  Library{Id: id, Date: date.UTC().Format(time.RFC3339), Active: loan.Library.Active, ReturnDate: current.Library.ReturnDate, DueDate: current.Library.DueDate, Renewals: current.Library.Renewals, Version: version + 1, UpdatedAt: now()},
+		IdBook:   loan.Book.Id,
+		IdClient: loan.Client.Id,
+		Renewals: current.Renewals,
+	}
+	switch {
+	case active && !loan.Library.Active:
+		updated.Library.ReturnDate = now().Format(time.RFC3339)
+		s.chargeFine(updated, fines)
+	case !active && loan.Library.Active:
+		updated.Library.ReturnDate = ""
+	}
+	s.loans[id] = updated
+	// book of active loan is free now
+	if active && (!loan.Library.Active || loan.Book.Id != current.IdBook) {
+		s.updateHolds(current.IdBook, pickup)
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteLoan(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.loans[id]
+	if err := checkVersion(current.Library.Version, ok, version); err != nil {
+		return err
+	}
+	delete(s.loans, id)
+	return nil
+}
+
+func (s *memoryStore) Checkout(ctx context.Context, bookId, clientId int, due time.Time) (LibraryJoin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkForeignKeys(LibraryRequestJoin{Book: Book{Id: bookId}, Client: Client{Id: clientId}}); err != nil {
+		return LibraryJoin{}, err
+	}
+	if s.activeLoans(bookId, 0) > 0 {
+		return LibraryJoin{}, ErrBookOnLoan
+	}
+	first, ok := s.firstHold(bookId)
+	if ok && first.IdClient != clientId {
+		return LibraryJoin{}, ErrBookOnHold
+	}
+	if ok {
+		first.Hold.Status = holdFulfilled
+		s.holds[first.Hold.Id] = first
+	}
+	s.lastLoanId++
+	loan := memoryLoan{
+		Library:  Library{Id: s.lastLoanId, Date: now().Format(time.RFC3339), DueDate: due.UTC().Format(time.RFC3339), Active: true, Version: 1, UpdatedAt: now()},
+		IdBook:   bookId,
+		IdClient: clientId,
+	}
+	s.loans[s.lastLoanId] = loan
+	join, _ := s.join(loan)
+	return join, nil
+}
+
+func (s *memoryStore) ReturnLoan(ctx context.Context, id int, pickup time.Duration, fines FinesConfig) (LibraryJoin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loan, ok := s.loans[id]
+	if !ok {
+		return LibraryJoin{}, ErrNotFound
+	}
+	if !loan.Library.Active {
+		return LibraryJoin{}, ErrLoanReturned
+	}
+	loan.Library.Active = false
+	loan.Library.ReturnDate = now().Format(time.RFC3339)
+	loan.Library.Version++
+	loan.Library.UpdatedAt = now()
+	s.loans[id] = loan
+	s.chargeFine(loan, fines)
+	s.updateHolds(loan.IdBook, pickup)
+	join, _ := s.join(loan)
+	return join, nil
+}
+
+// chargeFine charges client of loan returned now by fines, when it is late.
+func (s *memoryStore) chargeFine(loan memoryLoan, fines FinesConfig) {
+	if due, err := parseDate(loan.Library.DueDate); err == nil {
+		if fine, days := fines.overdueFine(due, now()); fine.IsPositive() {
+			s.addLedgerEntry(loan.IdClient, LedgerEntry{Kind: ledgerCharge, Amount: fine, Loan: loan.Library.Id, Note: overdueNote(days)})
+		}
+	}
+}
+
+func (s *memoryStore) RenewLoan(ctx context.Context, id int, period time.Duration, maxRenewals int) (LibraryJoin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loan, ok := s.loans[id]
+	if !ok {
+		return LibraryJoin{}, ErrNotFound
+	}
+	if !loan.Library.Active {
+		return LibraryJoin{}, ErrLoanReturned
+	}
+	if loan.Library.Renewals >= maxRenewals {
+		return LibraryJoin{}, ErrRenewalLimit
+	}
+	for _, hold := range s.holds {
+		if hold.IdBook == loan.IdBook && hold.IdClient != loan.IdClient && activeHold(hold.Hold, now()) {
+			return LibraryJoin{}, ErrBookOnHold
+		}
+	}
+	next := now().Add(period)
+	if due, err := parseDate(loan.Library.DueDate); err == nil {
+		next = due.Add(period)
+	}
+	s.lastRenewalId++
+	loan.Renewals = append(slices.Clip(loan.Renewals), Renewal{Id: s.lastRenewalId, Date: now().Format(time.RFC3339), PreviousDueDate: loan.Library.DueDate, DueDate: next.Format(time.RFC3339)})
+	loan.Library.DueDate = next.Format(time.RFC3339)
+	loan.Library.Renewals++
+	loan.Library.Version++
+	loan.Library.UpdatedAt = now()
+	s.loans[id] = loan
+	join, _ := s.join(loan)
+	return join, nil
+}
+
+func (s *memoryStore) GetRenewals(ctx context.Context, id int) ([]Renewal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	loan, ok := s.loans[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(loan.Renewals), nil
+}
+
+func (s *memoryStore) LoanStats(ctx context.Context, now time.Time) (LoanStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats LoanStats
+	for _, loan := range s.loans {
+		if !loan.Library.Active {
+			continue
+		}
+		stats.Active++
+		if due, err := parseDate(loan.Library.DueDate); err == nil && due.Before(now) {
+			stats.Overdue++
+		}
+	}
+	return stats, nil
+}
+
+// Holds
+
+// activeHold tells if hold is waiting or ready and not expired yet.
+func activeHold(hold Hold, at time.Time) bool {
+	expiry, err := parseDate(hold.ExpiryDate)
+	return hold.Status == holdWaiting || hold.Status == holdReady && err == nil && !expiry.Before(at)
+}
+
+// activeLoans counts active loans of book, of client when clientId is not 0.
+func (s *memoryStore) activeLoans(bookId, clientId int) int {
+	n := 0
+	for _, loan := range s.loans {
+		if loan.IdBook == bookId && loan.Library.Active && (clientId == 0 || loan.IdClient == clientId) {
+			n++
+		}
+	}
+	return n
+}
+
+// firstHold returns the first active hold of book.
+func (s *memoryStore) firstHold(bookId int) (memoryHold, bool) {
+	for _, id := range sortedIds(s.holds) {
+		if hold := s.holds[id]; hold.IdBook == bookId && activeHold(hold.Hold, now()) {
+			return hold, true
+		}
+	}
+	return memoryHold{}, false
+}
+
+// updateHolds mimics sqlStore.updateHolds.
+func (s *memoryStore) updateHolds(bookId int, pickup time.Duration) {
+	ready := false
+	for id, hold := range s.holds {
+		if hold.IdBook != bookId || hold.Hold.Status != holdReady {
+			continue
+		}
+		if activeHold(hold.Hold, now()) {
+			ready = true
+		} else {
+			hold.Hold.Status = holdExpired
+			s.holds[id] = hold
+		}
+	}
+	if ready || s.activeLoans(bookId, 0) > 0 {
+		return
+	}
+	for _, id := range sortedIds(s.holds) {
+		if hold := s.holds[id]; hold.IdBook == bookId && hold.Hold.Status == holdWaiting {
+			hold.Hold.Status = holdReady
+			hold.Hold.ReadyDate = now().Format(time.RFC3339)
+			hold.Hold.ExpiryDate = now().Add(pickup).Format(time.RFC3339)
+			s.holds[id] = hold
+			return
+		}
+	}
+}
+
+// joinHold resolves book and client of hold and computes its position in queue.
+func (s *memoryStore) joinHold(hold memoryHold) Hold {
+	joined := hold.Hold
+	joined.Book, joined.Client = s.books[hold.IdBook], s.clients[hold.IdClient]
+	if activeHold(joined, now()) {
+		for _, other := range s.holds {
+			if other.IdBook == hold.IdBook && other.Hold.Id <= hold.Hold.Id && activeHold(other.Hold, now()) {
+				joined.Position++
+			}
+		}
+	}
+	return joined
+}
+
+func (s *memoryStore) PlaceHold(ctx context.Context, bookId, clientId int) (Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkForeignKeys(LibraryRequestJoin{Book: Book{Id: bookId}, Client: Client{Id: clientId}}); err != nil {
+		return Hold{}, err
+	}
+	holds := 0
+	for _, hold := range s.holds {
+		if hold.IdBook != bookId || !activeHold(hold.Hold, now()) {
+			continue
+		}
+		if hold.IdClient == clientId {
+			return Hold{}, ErrHoldExists
+		}
+		holds++
+	}
+	if s.activeLoans(bookId, clientId) > 0 {
+		return Hold{}, ErrBookOnLoan
+	}
+	if holds == 0 && s.activeLoans(bookId, 0) == 0 {
+		return Hold{}, ErrBookAvailable
+	}
+	s.lastHoldId++
+	hold := memoryHold{
+		Hold:     Hold{Id: s.lastHoldId, Date: now().Format(time.RFC3339), Status: holdWaiting},
+		IdBook:   bookId,
+		IdClient: clientId,
+	}
+	s.holds[s.lastHoldId] = hold
+	return s.joinHold(hold), nil
+}
+
+func (s *memoryStore) BookHolds(ctx context.Context, bookId int) ([]Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.books[bookId]; !ok {
+		return nil, ErrNotFound
+	}
+	var holds []Hold
+	for _, id := range sortedIds(s.holds) {
+		if hold := s.holds[id]; hold.IdBook == bookId && activeHold(hold.Hold, now()) {
+			holds = append(holds, s.joinHold(hold))
+		}
+	}
+	return holds, nil
+}
+
+func (s *memoryStore) ClientHolds(ctx context.Context, clientId int) ([]Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.clients[clientId]; !ok {
+		return nil, ErrNotFound
+	}
+	var holds []Hold
+	for _, id := range sortedIds(s.holds) {
+		if hold := s.holds[id]; hold.IdClient == clientId {
+			holds = append(holds, s.joinHold(hold))
+		}
+	}
+	return holds, nil
+}
+
+func (s *memoryStore) CancelHold(ctx context.Context, clientId, id int, pickup time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hold, ok := s.holds[id]
+	if !ok || hold.IdClient != clientId {
+		return ErrNotFound
+	}
+	if hold.Hold.Status != holdWaiting && hold.Hold.Status != holdReady {
+		return ErrHoldClosed
+	}
+	hold.Hold.Status = holdCancelled
+	s.holds[id] = hold
+	s.updateHolds(hold.IdBook, pickup)
+	return nil
+}
+
+func (s *memoryStore) UpdateHolds(ctx context.Context, pickup time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := make(map[int]bool)
+	for _, hold := range s.holds {
+		if hold.Hold.Status == holdWaiting || hold.Hold.Status == holdReady {
+			books[hold.IdBook] = true
+		}
+	}
+	for bookId := range books {
+		s.updateHolds(bookId, pickup)
+	}
+	return nil
+}
+
+// Ledger
+
+func (s *memoryStore) addLedgerEntry(clientId int, entry LedgerEntry) LedgerEntry {
+	s.lastLedgerId++
+	entry.Id = s.lastLedgerId
+	entry.Date = now().Format(time.RFC3339)
+	s.ledger[entry.Id] = memoryLedgerEntry{Entry: entry, IdClient: clientId}
+	return entry
+}
+
+func (s *memoryStore) ledgerOf(clientId int) []LedgerEntry {
+	var entries []LedgerEntry
+	for _, id := range sortedIds(s.ledger) {
+		entry := s.ledger[id]
+		if entry.IdClient != clientId {
+			continue
+		}
+		if _, ok := s.loans[entry.Entry.Loan]; !ok {
+			entry.Entry.Loan = 0
+		}
+		entries = append(entries, entry.Entry)
+	}
+	return entries
+}
+
+func (s *memoryStore) GetLedger(ctx context.Context, clientId int) ([]LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.clients[clientId]; !ok {
+		return nil, ErrNotFound
+	}
+	return s.ledgerOf(clientId), nil
+}
+
+func (s *memoryStore) AddLedgerEntry(ctx context.Context, clientId int, entry LedgerEntry) (LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[clientId]; !ok {
+		return LedgerEntry{}, ErrNotFound
+	}
+	if loan, ok := s.loans[entry.Loan]; entry.Loan != 0 && (!ok || loan.IdClient != clientId) {
+		return LedgerEntry{}, ErrUnknownLoan
+	}
+	if entry.Kind != ledgerCharge && entry.Amount.GreaterThan(balanceOf(s.ledgerOf(clientId)).Balance) {
+		return LedgerEntry{}, ErrOverBalance
+	}
+	return s.addLedgerEntry(clientId, entry), nil
+}
@@ -0,0 +1,1246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// dialect describes differences between SQL databases. Queries in sqlStore are
+// written for MySQL and adjusted to dialect on execution.
+type dialect struct {
+	driver string
+	// numbered placeholders ($1, $2) instead of ?
+	numbered bool
+	// ids of inserted rows are read with RETURNING, database/sql LastInsertId is not supported
+	returning bool
+	// query returning database server version
+	version string
+	// books are searched with FULLTEXT index of column Search, other dialects use searchIndex
+	fulltext bool
+	// rows read in transactions are locked with FOR UPDATE, SQLite locks whole
+	// database when transaction begins instead
+	forUpdate bool
+	// query counting tables of the database with name ?
+	tables string
+}
+
+var (
+	mysqlDialect = dialect{driver: "mysql", version: "SELECT VERSION()", fulltext: true, forUpdate: true,
+		tables: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"}
+	sqliteDialect = dialect{driver: "sqlite", version: "SELECT 'SQLite ' || sqlite_version()",
+		tables: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"}
+	postgresDialect = dialect{driver: "postgres", numbered: true, returning: true, version: "SELECT version()", forUpdate: true,
+		tables: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"}
+)
+
+// sqlStore keeps data in a database/sql database: MySQL/MariaDB, SQLite or PostgreSQL.
+// Schema of each is created by migrations (see migrate.go).
+type sqlStore struct {
+	db *sql.DB
+	// statements run on db or on transaction of inTx
+	conn    querier
+	dialect dialect
+	// nil with fulltext dialect
+	index *searchIndex
+}
+
+func newSQLStore(dialect dialect, dsn string) (*sqlStore, error) {
+	// Create the database handle, confirm driver is present
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlStore{db: db, conn: db, dialect: dialect}
+	if !dialect.fulltext {
+		s.index = newSearchIndex()
+	}
+	return s, nil
+}
+
+func newMySQLStore(dsn string) (*sqlStore, error) {
+	return newSQLStore(mysqlDialect, dsn)
+}
+
+// newSQLiteStore opens (or creates) database file.
+// first_letter(name) is firstLetter for SQLite migrations, UPPER of SQLite
+// changes only ASCII letters.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("first_letter", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, _ := args[0].(string)
+		return firstLetter(text), nil
+	})
+}
+
+func newSQLiteStore(path string) (*sqlStore, error) {
+	// foreign keys are off by default in SQLite, they are needed for ON DELETE CASCADE;
+	// transactions take write lock on begin, concurrent ones wait for it up to busy_timeout
+	return newSQLStore(sqliteDialect, "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate")
+}
+
+func newPostgresStore(dsn string) (*sqlStore, error) {
+	return newSQLStore(postgresDialect, dsn)
+}
+
+// rebind rewrites ? placeholders for the dialect.
+func (s *sqlStore) rebind(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// querier is *sql.DB or *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// inTx runs fn with store executing statements in a transaction. It is committed
+// when fn returns nil, rolled back otherwise.
+func (s *sqlStore) inTx(ctx context.Context, fn func(tx *sqlStore) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	txStore := *s
+	txStore.conn = tx
+	if err := fn(&txStore); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// forUpdate locks rows read by query until the end of transaction.
+func (s *sqlStore) forUpdate(query string) string {
+	if s.dialect.forUpdate {
+		return query + " FOR UPDATE"
+	}
+	return query
+}
+
+func (s *sqlStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, end := s.startQuery(ctx, query)
+	row := s.conn.QueryRowContext(ctx, s.rebind(query), args...)
+	end(row.Err())
+	return row
+}
+
+func (s *sqlStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, end := s.startQuery(ctx, query)
+	rows, err := s.conn.QueryContext(ctx, s.rebind(query), args...)
+	end(err)
+	return rows, err
+}
+
+func (s *sqlStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, end := s.startQuery(ctx, query)
+	result, err := s.conn.ExecContext(ctx, s.rebind(query), args...)
+	end(err)
+	return result, err
+}
+
+// dateColumn makes column comparable with dates passed by sqlDate. SQLite keeps
+// dates as text in whatever format they were written.
+func (s *sqlStore) dateColumn(column string) string {
+	if s.dialect.driver == "sqlite" {
+		return "datetime(" + column + ")"
+	}
+	return column
+}
+
+// sqlDate formats t as query parameter understood by every dialect.
+func sqlDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// insert executes INSERT query and returns id of created row.
+func (s *sqlStore) insert(ctx context.Context, query string, args ...any) (int, error) {
+	if s.dialect.returning {
+		var id int
+		err := s.queryRow(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// DB is used to collect connection pool metrics.
+func (s *sqlStore) DB() *sql.DB {
+	return s.db
+}
+
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqlStore) Version(ctx context.Context) (string, error) {
+	var version string
+	err := s.db.QueryRowContext(ctx, s.dialect.version).Scan(&version)
+	return version, err
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// checkAffected returns ErrNotFound when UPDATE or DELETE matched no rows. MySQL
+// counts matched rather than changed rows thanks to clientFoundRows in DSN.
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// checkVersion tells why UPDATE or DELETE ... WHERE id = ? AND version = ? matched
+// no rows: the row does not exist or has other version.
+func (s *sqlStore) checkVersion(ctx context.Context, result sql.Result, table string, id int) error {
+	err := checkAffected(result)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	n, err := s.count(ctx, table+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+// dateScanner scans dates returned as time.Time or text, depending on driver and DSN.
+type dateScanner struct {
+	date *time.Time
+}
+
+func (d dateScanner) Scan(value any) error {
+	switch value := value.(type) {
+	case time.Time:
+		*d.date = value.UTC()
+	case []byte:
+		return d.Scan(string(value))
+	case string:
+		date, err := parseDate(value)
+		*d.date = date
+		return err
+	default:
+		return fmt.Errorf("can not scan %T into date", value)
+	}
+	return nil
+}
+
+// dateText scans nullable date into RFC 3339 text like dates of memoryStore,
+// SQLite returns them as "2006-01-02 15:04:05". NULL is empty text.
+type dateText struct {
+	text *string
+}
+
+func (d dateText) Scan(value any) error {
+	if value == nil {
+		*d.text = ""
+		return nil
+	}
+	var date time.Time
+	if err := (dateScanner{&date}).Scan(value); err != nil {
+		return err
+	}
+	*d.text = date.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// count returns number of rows of from clause, e.g. "book WHERE author = ?".
+func (s *sqlStore) count(ctx context.Context, from string, args ...any) (int, error) {
+	var n int
+	err := s.queryRow(ctx, "SELECT COUNT(*) FROM "+from, args...).Scan(&n)
+	return n, err
+}
+
+// column returns SQL expression of field comparable with sqlValue of its values.
+func (s *sqlStore) column(column string, kind fieldKind) string {
+	if kind == kindDate {
+		return s.dateColumn(column)
+	}
+	return column
+}
+
+func sqlValue(value any) any {
+	if date, ok := value.(time.Time); ok {
+		return sqlDate(date)
+	}
+	return value
+}
+
+// likeEscaper escapes LIKE wildcards, ! is escape character as backslash
+// means different things in MySQL and PostgreSQL string literals.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// filterConditions translates filters into conditions joined with AND and their arguments.
+func filterConditions[T any](s *sqlStore, fields []listField[T], filters []Filter) ([]string, []any) {
+	var conditions []string
+	var args []any
+	for _, filter := range filters {
+		field, _ := findField(fields, filter.Field)
+		column := s.column(field.Column, field.Kind)
+		switch filter.Op {
+		case opContains:
+			conditions = append(conditions, "LOWER("+column+") LIKE ? ESCAPE '!'")
+			args = append(args, "%"+likeEscaper.Replace(strings.ToLower(filter.Value.(string)))+"%")
+		case opEqual, opFrom, opTo, opBefore:
+			conditions = append(conditions, column+" "+filter.Op+" ?")
+			args = append(args, sqlValue(filter.Value))
+		}
+	}
+	return conditions, args
+}
+
+// listClauses translates q into WHERE of filters (for counting) and WHERE of filters
+// and cursor with ORDER BY, LIMIT and OFFSET (for reading the page). Fields come
+// from whitelist, values are parameters.
+func listClauses[T any](s *sqlStore, fields []listField[T], q ListQuery) (string, []any, string, []any) {
+	conditions, args := filterConditions(s, fields, q.Filters)
+	countClause, countArgs := "", args
+	if len(conditions) > 0 {
+		countClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	pageArgs := append([]any{}, args...)
+	if len(q.After) > 0 {
+		// keyset pagination: (a > ?) OR (a = ? AND b > ?) OR ... for sort a, b,
+		// < for descending fields
+		var keyset []string
+		for i := range q.Sort {
+			var terms []string
+			for j := 0; j <= i; j++ {
+				field, _ := findField(fields, q.Sort[j].Field)
+				op := "="
+				if j == i {
+					op = ">"
+					if q.Sort[j].Desc {
+						op = "<"
+					}
+				}
+				terms = append(terms, s.column(field.Column, field.Kind)+" "+op+" ?")
+				pageArgs = append(pageArgs, sqlValue(q.After[j]))
+			}
+			keyset = append(keyset, "("+strings.Join(terms, " AND ")+")")
+		}
+		conditions = append(conditions, "("+strings.Join(keyset, " OR ")+")")
+	}
+	var pageClause string
+	if len(conditions) > 0 {
+		pageClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	var order []string
+	for _, sort := range q.Sort {
+		field, _ := findField(fields, sort.Field)
+		column := s.column(field.Column, field.Kind)
+		if sort.Desc {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	pageClause += " ORDER BY " + strings.Join(order, ", ") + " LIMIT ?"
+	pageArgs = append(pageArgs, q.Limit)
+	if q.Offset > 0 {
+		pageClause += " OFFSET ?"
+		pageArgs = append(pageArgs, q.Offset)
+	}
+	return countClause, countArgs, pageClause, pageArgs
+}
+
+// Books
+
+func (s *sqlStore) GetBook(ctx context.Context, id int) (Book, error) {
+	book := Book{Id: id}
+	err := s.queryRow(ctx, "SELECT name, author, version, updated_at FROM book WHERE id = ?", id).Scan(&book.Name, &book.Author, &book.Version, dateScanner{&book.UpdatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return book, ErrNotFound
+	}
+	return book, err
+}
+
+func (s *sqlStore) GetBooks(ctx context.Context, q ListQuery) ([]Book, int, error) {
+	var books []Book
+
+	countClause, countArgs, pageClause, pageArgs := listClauses(s, bookFields, q)
+	total, err := s.count(ctx, "book"+countClause, countArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.query(ctx, "SELECT id, name, author, version, updated_at FROM book"+pageClause, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var book Book
+		if err := rows.Scan(&book.Id, &book.Name, &book.Author, &book.Version, dateScanner{&book.UpdatedAt}); err != nil {
+			return nil, 0, err
+		}
+		books = append(books, book)
+	}
+	return books, total, rows.Err()
+}
+
+func (s *sqlStore) BookFacets(ctx context.Context, filters []Filter, facets []Facet) (map[string][]FacetCount, error) {
+	var where string
+	conditions, args := filterConditions(s, bookFields, filters)
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	counts := make(map[string][]FacetCount, len(facets))
+	for _, facet := range facets {
+		field, _ := findField(bookFields, facet.Name)
+		query, queryArgs := "SELECT "+field.Column+", COUNT(*) FROM book"+where+" GROUP BY 1", args
+		if facet.Limit > 0 {
+			query += " ORDER BY 2 DESC, 1 LIMIT ?"
+			queryArgs = append(append([]any{}, args...), facet.Limit)
+		} else {
+			query += " ORDER BY 1"
+		}
+		rows, err := s.query(ctx, query, queryArgs...)
+		if err != nil {
+			return nil, err
+		}
+		counts[facet.Name] = []FacetCount{}
+		for rows.Next() {
+			var count FacetCount
+			var err error
+			switch field.Kind {
+			case kindBool:
+				var value bool
+				err = rows.Scan(&value, &count.Count)
+				count.Value = value
+			default:
+				var value string
+				err = rows.Scan(&value, &count.Count)
+				count.Value = value
+			}
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			counts[facet.Name] = append(counts[facet.Name], count)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+func (s *sqlStore) CreateBook(ctx context.Context, book BookRequest) (int, error) {
+	now := sqlDate(time.Now())
+	if s.dialect.fulltext {
+		return s.insert(ctx, "INSERT INTO book (Name, Author, Letter, Search, Updated_At) VALUES (?, ?, ?, ?, ?)", book.Name, book.Author, firstLetter(book.Name), searchText(book), now)
+	}
+	id, err := s.insert(ctx, "INSERT INTO book (Name, Author, Letter, Updated_At) VALUES (?, ?, ?, ?)", book.Name, book.Author, firstLetter(book.Name), now)
+	if err == nil {
+		s.index.Put(Book{Id: id, Name: book.Name, Author: book.Author})
+	}
+	return id, err
+}
+
+func (s *sqlStore) UpdateBook(ctx context.Context, id, version int, book BookRequest) error {
+	now := sqlDate(time.Now())
+	if s.dialect.fulltext {
+		result, err := s.exec(ctx, "UPDATE book SET Name = ?, Author = ?, Letter = ?, Search = ?, Version = Version + 1, Updated_At = ? WHERE Id = ? AND Version = ?", book.Name, book.Author, firstLetter(book.Name), searchText(book), now, id, version)
+		if err != nil {
+			return err
+		}
+		return s.checkVersion(ctx, result, "book", id)
+	}
+	result, err := s.exec(ctx, "UPDATE book SET Name = ?, Author = ?, Letter = ?, Version = Version + 1, Updated_At = ? WHERE Id = ? AND Version = ?", book.Name, book.Author, firstLetter(book.Name), now, id, version)
+	if err != nil {
+		return err
+	}
+	if err := s.checkVersion(ctx, result, "book", id); err != nil {
+		return err
+	}
+	s.index.Put(Book{Id: id, Name: book.Name, Author: book.Author})
+	return nil
+}
+
+func (s *sqlStore) DeleteBook(ctx context.Context, id, version int) error {
+	result, err := s.exec(ctx, "DELETE FROM book WHERE id = ? AND version = ?", id, version)
+	if err != nil {
+		return err
+	}
+	if err := s.checkVersion(ctx, result, "book", id); err != nil {
+		return err
+	}
+	if s.index != nil {
+		s.index.Remove(id)
+	}
+	return nil
+}
+
+func (s *sqlStore) SearchBooks(ctx context.Context, q SearchQuery) ([]SearchHit, int, error) {
+	if s.index != nil {
+		hits, total := s.index.Search(q)
+		return hits, total, nil
+	}
+
+	// boolean mode: every word is required, * matches words starting with term;
+	// terms are letters and digits only, they can't be operators
+	var against []string
+	for _, term := range q.Terms {
+		against = append(against, "+"+term+"*")
+	}
+	match := "MATCH(Search) AGAINST(? IN BOOLEAN MODE)"
+	total, err := s.count(ctx, "book WHERE "+match, strings.Join(against, " "))
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.query(ctx, "SELECT id, name, author, "+match+" AS score FROM book WHERE "+match+" ORDER BY score DESC, id LIMIT ? OFFSET ?", strings.Join(against, " "), strings.Join(against, " "), q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var hits []SearchHit
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.Book.Id, &hit.Book.Name, &hit.Book.Author, &hit.Score); err != nil {
+			return nil, 0, err
+		}
+		hit.Score = math.Round(hit.Score*1000) / 1000
+		hits = append(hits, hit)
+	}
+	return hits, total, rows.Err()
+}
+
+// BuildSearchIndex reads all books into searchIndex, MySQL keeps its own index.
+func (s *sqlStore) BuildSearchIndex(ctx context.Context) error {
+	if s.index == nil {
+		return nil
+	}
+	rows, err := s.query(ctx, "SELECT id, name, author FROM book")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var book Book
+		if err := rows.Scan(&book.Id, &book.Name, &book.Author); err != nil {
+			return err
+		}
+		s.index.Put(book)
+	}
+	return rows.Err()
+}
+
+// Clients
+
+func (s *sqlStore) GetClient(ctx context.Context, id int) (Client, error) {
+	client := Client{Id: id}
+	err := s.queryRow(ctx, "SELECT name, version, updated_at FROM client WHERE id = ?", id).Scan(&client.Name, &client.Version, dateScanner{&client.UpdatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return client, ErrNotFound
+	}
+	return client, err
+}
+
+func (s *sqlStore) GetClients(ctx context.Context, q ListQuery) ([]Client, int, error) {
+	var clients []Client
+
+	countClause, countArgs, pageClause, pageArgs := listClauses(s, clientFields, q)
+	total, err := s.count(ctx, "client"+countClause, countArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.query(ctx, "SELECT id, name, version, updated_at FROM client"+pageClause, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var client Client
+		if err := rows.Scan(&client.Id, &client.Name, &client.Version, dateScanner{&client.UpdatedAt}); err != nil {
+			return nil, 0, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, total, rows.Err()
+}
+
+func (s *sqlStore) CreateClient(ctx context.Context, client ClientRequest) (int, error) {
+	return s.insert(ctx, "INSERT INTO client (Name, Updated_At) VALUES (?, ?)", client.Name, sqlDate(time.Now()))
+}
+
+func (s *sqlStore) UpdateClient(ctx context.Context, id, version int, client ClientRequest) error {
+	result, err := s.exec(ctx, "UPDATE client SET Name = ?, Version = Version + 1, Updated_At = ? WHERE Id = ? AND Version = ?", client.Name, sqlDate(time.Now()), id, version)
+	if err != nil {
+		return err
+	}
+	return s.checkVersion(ctx, result, "client", id)
+}
+
+func (s *sqlStore) DeleteClient(ctx context.Context, id, version int) error {
+	result, err := s.exec(ctx, "DELETE FROM client WHERE id = ? AND version = ?", id, version)
+	if err != nil {
+		return err
+	}
+	return s.checkVersion(ctx, result, "client", id)
+}
+
+// Libraries
+
+const selectLoan = "SELECT library.id, id_book, book.name, book.author, book.version, book.updated_at, id_client, client.name, client.version, client.updated_at, date, due_date, renewals, returned_at, active, library.version, library.updated_at FROM library INNER JOIN book ON library.id_book = book.id INNER JOIN client ON library.id_client = client.id"
+
+func scanLoan(row interface{ Scan(...any) error }) (LibraryJoin, error) {
+	var loan LibraryJoin
+	err := row.Scan(
+		&loan.Library.Id,
+		&loan.Book.Id, &loan.Book.Name, &loan.Book.Author, &loan.Book.Version, dateScanner{&loan.Book.UpdatedAt},
+		&loan.Client.Id, &loan.Client.Name, &loan.Client.Version, dateScanner{&loan.Client.UpdatedAt},
+		dateText{&loan.Library.Date}, dateText{&loan.Library.DueDate}, &loan.Library.Renewals, dateText{&loan.Library.ReturnDate}, &loan.Library.Active, &loan.Library.Version, dateScanner{&loan.Library.UpdatedAt},
+	)
+	setOverdue(&loan.Library, time.Now())
+	return loan, err
+}
+
+func (s *sqlStore) GetLoan(ctx context.Context, id int) (LibraryJoin, error) {
+	loan, err := scanLoan(s.queryRow(ctx, selectLoan+" WHERE library.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return loan, ErrNotFound
+	}
+	return loan, err
+}
+
+func (s *sqlStore) GetLoans(ctx context.Context, q ListQuery) ([]LibraryJoin, int, error) {
+	var loans []LibraryJoin
+
+	countClause, countArgs, pageClause, pageArgs := listClauses(s, loanFields, q)
+	total, err := s.count(ctx, "library"+countClause, countArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.query(ctx, selectLoan+pageClause, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		loans = append(loans, loan)
+	}
+	return loans, total, rows.Err()
+}
+
+// checkReferences reports missing book or client before foreign keys fail with driver specific errors.
+func (s *sqlStore) checkReferences(ctx context.Context, loan LibraryRequestJoin) error {
+	var books, clients int
+	err := s.queryRow(ctx, "SELECT (SELECT COUNT(*) FROM book WHERE id = ?), (SELECT COUNT(*) FROM client WHERE id = ?)", loan.Book.Id, loan.Client.Id).Scan(&books, &clients)
+	if err != nil {
+		return err
+	}
+	if books == 0 {
+		return ErrUnknownBook
+	}
+	if clients == 0 {
+		return ErrUnknownClient
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateLoan(ctx context.Context, loan LibraryRequestJoin, due time.Time) (int, error) {
+	var id int
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.lockBook(ctx, loan.Book.Id); err != nil {
+			return err
+		}
+		if err := tx.checkReferences(ctx, loan); err != nil {
+			return err
+		}
+		if loan.Library.Active {
+			if err := tx.checkOnLoan(ctx, loan.Book.Id, 0); err != nil {
+				return err
+			}
+		}
+		var err error
+		date := sqlDate(time.Now())
+		id, err = tx.insert(ctx, "INSERT INTO library (id_book, id_client, date, due_date, active, updated_at) VALUES (?, ?, ?, ?, ?, ?)", loan.Book.Id, loan.Client.Id, date, sqlDate(due), loan.Library.Active, date)
+		return err
+	})
+	return id, err
+}
+
+func (s *sqlStore) UpdateLoan(ctx context.Context, id, version int, loan LibraryRequestJoin, pickup time.Duration, fines FinesConfig) error {
+	date, err := parseDate(loan.Library.Date)
+	if err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		bookId, err := tx.lockLoan(ctx, id)
+		if err != nil {
+			return err
+		}
+		if loan.Book.Id != bookId {
+			if err := tx.lockBook(ctx, loan.Book.Id); err != nil {
+				return err
+			}
+		}
+		if err := tx.checkReferences(ctx, loan); err != nil {
+			return err
+		}
+		var active bool
+		var current int
+		var due sql.NullString
+		err = tx.queryRow(ctx, "SELECT active, version, due_date FROM library WHERE id = ?", id).Scan(&active, &current, &due)
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return ErrVersionConflict
+		}
+		if loan.Library.Active {
+			if err := tx.checkOnLoan(ctx, loan.Book.Id, id); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		_, err = tx.exec(ctx, "UPDATE library SET Id_book = ?, Id_client = ?, Date = ?, Active = ?, Version = Version + 1, Updated_At = ? WHERE Id = ?", loan.Book.Id, loan.Client.Id, sqlDate(date), loan.Library.Active, sqlDate(now), id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case active && !loan.Library.Active:
+			err = tx.stampReturn(ctx, id, loan.Client.Id, due, now, fines)
+		case !active && loan.Library.Active:
+			_, err = tx.exec(ctx, "UPDATE library SET Returned_At = NULL WHERE Id = ?", id)
+		}
+		if err != nil {
+			return err
+		}
+		// book of active loan is free now
+		if active && (!loan.Library.Active || loan.Book.Id != bookId) {
+			return tx.updateHolds(ctx, bookId, pickup)
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) DeleteLoan(ctx context.Context, id, version int) error {
+	result, err := s.exec(ctx, "DELETE FROM library WHERE id = ? AND version = ?", id, version)
+	if err != nil {
+		return err
+	}
+	return s.checkVersion(ctx, result, "library", id)
+}
+
+// lockBook locks row of book until the end of transaction. Checkouts, returns,
+// renewals and holds of the book lock it, so they run one by one.
+func (s *sqlStore) lockBook(ctx context.Context, bookId int) error {
+	var locked int
+	err := s.queryRow(ctx, s.forUpdate("SELECT id FROM book WHERE id = ?"), bookId).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownBook
+	}
+	return err
+}
+
+// lockLoan locks row of loan, then its book, and returns id of the book. The
+// loan is locked first so its book can not change before the book is locked.
+func (s *sqlStore) lockLoan(ctx context.Context, id int) (int, error) {
+	var bookId int
+	err := s.queryRow(ctx, s.forUpdate("SELECT id_book FROM library WHERE id = ?"), id).Scan(&bookId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := s.lockBook(ctx, bookId); errors.Is(err, ErrUnknownBook) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	return bookId, nil
+}
+
+// checkOnLoan fails with ErrBookOnLoan when book has an active loan other than loan id.
+func (s *sqlStore) checkOnLoan(ctx context.Context, bookId, id int) error {
+	active, err := s.count(ctx, "library WHERE id_book = ? AND active = ? AND id <> ?", bookId, true, id)
+	if err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrBookOnLoan
+	}
+	return nil
+}
+
+func (s *sqlStore) Checkout(ctx context.Context, bookId, clientId int, due time.Time) (LibraryJoin, error) {
+	var id int
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		// concurrent checkouts of the book wait here until this one commits
+		if err := tx.lockBook(ctx, bookId); err != nil {
+			return err
+		}
+		if err := tx.checkReferences(ctx, LibraryRequestJoin{Book: Book{Id: bookId}, Client: Client{Id: clientId}}); err != nil {
+			return err
+		}
+		if err := tx.checkOnLoan(ctx, bookId, 0); err != nil {
+			return err
+		}
+		holdId, holder, err := tx.firstHold(ctx, bookId)
+		if err != nil {
+			return err
+		}
+		if holdId != 0 && holder != clientId {
+			return ErrBookOnHold
+		}
+		date := sqlDate(time.Now())
+		id, err = tx.insert(ctx, "INSERT INTO library (id_book, id_client, date, due_date, active, updated_at) VALUES (?, ?, ?, ?, ?, ?)", bookId, clientId, date, sqlDate(due), true, date)
+		if err != nil || holdId == 0 {
+			return err
+		}
+		_, err = tx.exec(ctx, "UPDATE hold SET status = ? WHERE id = ?", holdFulfilled, holdId)
+		return err
+	})
+	if err != nil {
+		return LibraryJoin{}, err
+	}
+	return s.GetLoan(ctx, id)
+}
+
+func (s *sqlStore) ReturnLoan(ctx context.Context, id int, pickup time.Duration, fines FinesConfig) (LibraryJoin, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		bookId, err := tx.lockLoan(ctx, id)
+		if err != nil {
+			return err
+		}
+		var active bool
+		var clientId int
+		var due sql.NullString
+		err = tx.queryRow(ctx, "SELECT active, id_client, due_date FROM library WHERE id = ?", id).Scan(&active, &clientId, &due)
+		if err != nil {
+			return err
+		}
+		if !active {
+			return ErrLoanReturned
+		}
+		returned := time.Now()
+		_, err = tx.exec(ctx, "UPDATE library SET Active = ?, Version = Version + 1, Updated_At = ? WHERE Id = ?", false, sqlDate(returned), id)
+		if err != nil {
+			return err
+		}
+		if err := tx.stampReturn(ctx, id, clientId, due, returned, fines); err != nil {
+			return err
+		}
+		return tx.updateHolds(ctx, bookId, pickup)
+	})
+	if err != nil {
+		return LibraryJoin{}, err
+	}
+	return s.GetLoan(ctx, id)
+}
+
+// stampReturn stamps return time of loan and charges its client by fines when
+// the loan is returned after due date.
+func (s *sqlStore) stampReturn(ctx context.Context, id, clientId int, due sql.NullString, returned time.Time, fines FinesConfig) error {
+	date := sqlDate(returned)
+	if _, err := s.exec(ctx, "UPDATE library SET Returned_At = ? WHERE Id = ?", date, id); err != nil {
+		return err
+	}
+	dueDate, err := parseDate(due.String)
+	if err != nil {
+		return nil
+	}
+	if fine, days := fines.overdueFine(dueDate, returned); fine.IsPositive() {
+		_, err = s.exec(ctx, "INSERT INTO ledger (id_client, id_library, date, kind, amount, note) VALUES (?, ?, ?, ?, ?, ?)", clientId, id, date, ledgerCharge, fine, overdueNote(days))
+		return err
+	}
+	return nil
+}
+
+func (s *sqlStore) RenewLoan(ctx context.Context, id int, period time.Duration, maxRenewals int) (LibraryJoin, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		bookId, err := tx.lockLoan(ctx, id)
+		if err != nil {
+			return err
+		}
+		var active bool
+		var clientId, renewals int
+		var due sql.NullString
+		err = tx.queryRow(ctx, "SELECT active, id_client, renewals, due_date FROM library WHERE id = ?", id).Scan(&active, &clientId, &renewals, &due)
+		if err != nil {
+			return err
+		}
+		if !active {
+			return ErrLoanReturned
+		}
+		if renewals >= maxRenewals {
+			return ErrRenewalLimit
+		}
+		condition, args := tx.activeHold("hold", time.Now())
+		holds, err := tx.count(ctx, "hold WHERE id_book = ? AND id_client <> ? AND "+condition, append([]any{bookId, clientId}, args...)...)
+		if err != nil {
+			return err
+		}
+		if holds > 0 {
+			return ErrBookOnHold
+		}
+		// loans without due date are renewed from now
+		var previous any
+		next, now := time.Now().Add(period), sqlDate(time.Now())
+		if date, err := parseDate(due.String); err == nil {
+			previous, next = sqlDate(date), date.Add(period)
+		}
+		_, err = tx.exec(ctx, "UPDATE library SET Due_Date = ?, Renewals = Renewals + 1, Version = Version + 1, Updated_At = ? WHERE Id = ?", sqlDate(next), now, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.insert(ctx, "INSERT INTO renewal (id_library, date, previous_due_date, due_date) VALUES (?, ?, ?, ?)", id, now, previous, sqlDate(next))
+		return err
+	})
+	if err != nil {
+		return LibraryJoin{}, err
+	}
+	return s.GetLoan(ctx, id)
+}
+
+func (s *sqlStore) GetRenewals(ctx context.Context, id int) ([]Renewal, error) {
+	loans, err := s.count(ctx, "library WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if loans == 0 {
+		return nil, ErrNotFound
+	}
+	rows, err := s.query(ctx, "SELECT id, date, previous_due_date, due_date FROM renewal WHERE id_library = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var renewals []Renewal
+	for rows.Next() {
+		var renewal Renewal
+		if err := rows.Scan(&renewal.Id, dateText{&renewal.Date}, dateText{&renewal.PreviousDueDate}, dateText{&renewal.DueDate}); err != nil {
+			return nil, err
+		}
+		renewals = append(renewals, renewal)
+	}
+	return renewals, rows.Err()
+}
+
+func (s *sqlStore) LoanStats(ctx context.Context, now time.Time) (LoanStats, error) {
+	var stats LoanStats
+	err := s.queryRow(ctx, "SELECT COUNT(*), COALESCE(SUM(CASE WHEN "+s.dateColumn("due_date")+" < ? THEN 1 ELSE 0 END), 0) FROM library WHERE active = ?", sqlDate(now), true).Scan(&stats.Active, &stats.Overdue)
+	return stats, err
+}
+
+// Holds
+
+func scanHold(row interface{ Scan(...any) error }) (Hold, error) {
+	var hold Hold
+	err := row.Scan(&hold.Id, &hold.Book.Id, &hold.Book.Name, &hold.Book.Author, &hold.Client.Id, &hold.Client.Name, dateText{&hold.Date}, &hold.Status, dateText{&hold.ReadyDate}, dateText{&hold.ExpiryDate}, &hold.Position)
+	return hold, err
+}
+
+// queryHolds selects holds with their book, client and position in queue of active holds.
+func (s *sqlStore) queryHolds(ctx context.Context, where string, args ...any) ([]Hold, error) {
+	active, activeArgs := s.activeHold("hold", time.Now())
+	queued, queuedArgs := s.activeHold("queue", time.Now())
+	query := "SELECT hold.id, id_book, book.name, book.author, id_client, client.name, hold.date, hold.status, ready_at, expires_at, " +
+		"CASE WHEN " + active + " THEN (SELECT COUNT(*) FROM hold queue WHERE queue.id_book = hold.id_book AND " + queued + " AND queue.id <= hold.id) ELSE 0 END " +
+		"FROM hold INNER JOIN book ON hold.id_book = book.id INNER JOIN client ON hold.id_client = client.id WHERE " + where + " ORDER BY hold.id"
+	rows, err := s.query(ctx, query, append(append(activeArgs, queuedArgs...), args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var holds []Hold
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// activeHold is condition of holds (table or alias) waiting or ready and not expired yet, with its arguments.
+func (s *sqlStore) activeHold(table string, now time.Time) (string, []any) {
+	return "(" + table + ".status = ? OR " + table + ".status = ? AND " + s.dateColumn(table+".expires_at") + " >= ?)", []any{holdWaiting, holdReady, sqlDate(now)}
+}
+
+// firstHold returns id and client of the first active hold of book, zeros when the queue is empty.
+func (s *sqlStore) firstHold(ctx context.Context, bookId int) (int, int, error) {
+	var id, clientId int
+	condition, args := s.activeHold("hold", time.Now())
+	err := s.queryRow(ctx, "SELECT id, id_client FROM hold WHERE id_book = ? AND "+condition+" ORDER BY id LIMIT 1", append([]any{bookId}, args...)...).Scan(&id, &clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, nil
+	}
+	return id, clientId, err
+}
+
+// updateHolds expires ready hold of locked book not picked up in time. When
+// the book is neither on loan nor ready for anyone, the first waiting hold
+// becomes ready.
+func (s *sqlStore) updateHolds(ctx context.Context, bookId int, pickup time.Duration) error {
+	now := time.Now()
+	_, err := s.exec(ctx, "UPDATE hold SET status = ? WHERE id_book = ? AND status = ? AND "+s.dateColumn("expires_at")+" < ?", holdExpired, bookId, holdReady, sqlDate(now))
+	if err != nil {
+		return err
+	}
+	var ready, active int
+	err = s.queryRow(ctx, "SELECT (SELECT COUNT(*) FROM hold WHERE id_book = ? AND status = ?), (SELECT COUNT(*) FROM library WHERE id_book = ? AND active = ?)", bookId, holdReady, bookId, true).Scan(&ready, &active)
+	if err != nil || ready > 0 || active > 0 {
+		return err
+	}
+	var id int
+	err = s.queryRow(ctx, "SELECT id FROM hold WHERE id_book = ? AND status = ? ORDER BY id LIMIT 1", bookId, holdWaiting).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, "UPDATE hold SET status = ?, ready_at = ?, expires_at = ? WHERE id = ?", holdReady, sqlDate(now), sqlDate(now.Add(pickup)), id)
+	return err
+}
+
+func (s *sqlStore) PlaceHold(ctx context.Context, bookId, clientId int) (Hold, error) {
+	var id int
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.lockBook(ctx, bookId); err != nil {
+			return err
+		}
+		if err := tx.checkReferences(ctx, LibraryRequestJoin{Book: Book{Id: bookId}, Client: Client{Id: clientId}}); err != nil {
+			return err
+		}
+		condition, holdArgs := tx.activeHold("hold", time.Now())
+		args := append([]any{bookId}, holdArgs...)
+		args = append(append(args, bookId, clientId), holdArgs...)
+		args = append(args, bookId, true, bookId, clientId, true)
+		var holds, own, loans, borrowed int
+		err := tx.queryRow(ctx, "SELECT "+
+			"(SELECT COUNT(*) FROM hold WHERE id_book = ? AND "+condition+"), "+
+			"(SELECT COUNT(*) FROM hold WHERE id_book = ? AND id_client = ? AND "+condition+"), "+
+			"(SELECT COUNT(*) FROM library WHERE id_book = ? AND active = ?), "+
+			"(SELECT COUNT(*) FROM library WHERE id_book = ? AND id_client = ? AND active = ?)", args...,
+		).Scan(&holds, &own, &loans, &borrowed)
+		switch {
+		case err != nil:
+			return err
+		case own > 0:
+			return ErrHoldExists
+		case borrowed > 0:
+			return ErrBookOnLoan
+		case holds == 0 && loans == 0:
+			return ErrBookAvailable
+		}
+		id, err = tx.insert(ctx, "INSERT INTO hold (id_book, id_client, date, status) VALUES (?, ?, ?, ?)", bookId, clientId, sqlDate(time.Now()), holdWaiting)
+		return err
+	})
+	if err != nil {
+		return Hold{}, err
+	}
+	holds, err := s.queryHolds(ctx, "hold.id = ?", id)
+	if err != nil {
+		return Hold{}, err
+	}
+	if len(holds) == 0 {
+		return Hold{}, ErrNotFound
+	}
+	return holds[0], nil
+}
+
+func (s *sqlStore) BookHolds(ctx context.Context, bookId int) ([]Hold, error) {
+	books, err := s.count(ctx, "book WHERE id = ?", bookId)
+	if err != nil {
+		return nil, err
+	}
+	if books == 0 {
+		return nil, ErrNotFound
+	}
+	condition, args := s.activeHold("hold", time.Now())
+	return s.queryHolds(ctx, "hold.id_book = ? AND "+condition, append([]any{bookId}, args...)...)
+}
+
+func (s *sqlStore) ClientHolds(ctx context.Context, clientId int) ([]Hold, error) {
+	clients, err := s.count(ctx, "client WHERE id = ?", clientId)
+	if err != nil {
+		return nil, err
+	}
+	if clients == 0 {
+		return nil, ErrNotFound
+	}
+	return s.queryHolds(ctx, "hold.id_client = ?", clientId)
+}
+
+func (s *sqlStore) CancelHold(ctx context.Context, clientId, id int, pickup time.Duration) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		var bookId, holder int
+		err := tx.queryRow(ctx, "SELECT id_book, id_client FROM hold WHERE id = ?", id).Scan(&bookId, &holder)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && holder != clientId {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.lockBook(ctx, bookId); err != nil {
+			return err
+		}
+		result, err := tx.exec(ctx, "UPDATE hold SET status = ? WHERE id = ? AND status IN (?, ?)", holdCancelled, id, holdWaiting, holdReady)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(result); errors.Is(err, ErrNotFound) {
+			return ErrHoldClosed
+		} else if err != nil {
+			return err
+		}
+		return tx.updateHolds(ctx, bookId, pickup)
+	})
+}
+
+func (s *sqlStore) UpdateHolds(ctx context.Context, pickup time.Duration) error {
+	rows, err := s.query(ctx, "SELECT DISTINCT id_book FROM hold WHERE status IN (?, ?)", holdWaiting, holdReady)
+	if err != nil {
+		return err
+	}
+	var books []int
+	for rows.Next() {
+		var bookId int
+		if err := rows.Scan(&bookId); err != nil {
+			rows.Close()
+			return err
+		}
+		books = append(books, bookId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, bookId := range books {
+		err := s.inTx(ctx, func(tx *sqlStore) error {
+			if err := tx.lockBook(ctx, bookId); err != nil {
+				return err
+			}
+			return tx.updateHolds(ctx, bookId, pickup)
+		})
+		if err != nil && !errors.Is(err, ErrUnknownBook) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ledger
+
+func (s *sqlStore) GetLedger(ctx context.Context, clientId int) ([]LedgerEntry, error) {
+	clients, err := s.count(ctx, "client WHERE id = ?", clientId)
+	if err != nil {
+		return nil, err
+	}
+	if clients == 0 {
+		return nil, ErrNotFound
+	}
+	return s.queryLedger(ctx, "id_client = ?", clientId)
+}
+
+// queryLedger returns ledger entries matching where in order of ids.
+func (s *sqlStore) queryLedger(ctx context.Context, where string, args ...any) ([]LedgerEntry, error) {
+	rows, err := s.query(ctx, "SELECT id, date, kind, amount, id_library, note FROM ledger WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []LedgerEntry
+	for rows.Next() {
+		var entry LedgerEntry
+		var loan sql.NullInt64
+		if err := rows.Scan(&entry.Id, dateText{&entry.Date}, &entry.Kind, &entry.Amount, &loan, &entry.Note); err != nil {
+			return nil, err
+		}
+		entry.Loan = int(loan.Int64)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqlStore) AddLedgerEntry(ctx context.Context, clientId int, entry LedgerEntry) (LedgerEntry, error) {
+	var id int
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		// concurrent payments of client wait, so they can not exceed balance together
+		var locked int
+		err := tx.queryRow(ctx, tx.forUpdate("SELECT id FROM client WHERE id = ?"), clientId).Scan(&locked)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		loan := sql.NullInt64{Int64: int64(entry.Loan), Valid: entry.Loan != 0}
+		if loan.Valid {
+			loans, err := tx.count(ctx, "library WHERE id = ? AND id_client = ?", entry.Loan, clientId)
+			if err != nil {
+				return err
+			}
+			if loans == 0 {
+				return ErrUnknownLoan
+			}
+		}
+		if entry.Kind != ledgerCharge {
+			entries, err := tx.queryLedger(ctx, "id_client = ?", clientId)
+			if err != nil {
+				return err
+			}
+			if entry.Amount.GreaterThan(balanceOf(entries).Balance) {
+				return ErrOverBalance
+			}
+		}
+		id, err = tx.insert(ctx, "INSERT INTO ledger (id_client, id_library, date, kind, amount, note) VALUES (?, ?, ?, ?, ?, ?)", clientId, loan, sqlDate(time.Now()), entry.Kind, entry.Amount, entry.Note)
+		return err
+	})
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+	entries, err := s.queryLedger(ctx, "id = ?", id)
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+	if len(entries) == 0 {
+		return LedgerEntry{}, ErrNotFound
+	}
+	return entries[0], nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	query := "UPDATE book SET name = ?, author = ? WHERE id = ?"
+	tests := []struct {
+		dialect dialect
+		want    string
+	}{
+		{mysqlDialect, query},
+		{sqliteDialect, query},
+		{postgresDialect, "UPDATE book SET name = $1, author = $2 WHERE id = $3"},
+	}
+	for _, test := range tests {
+		s := &sqlStore{dialect: test.dialect}
+		if got := s.rebind(query); got != test.want {
+			t.Errorf("%s: rebind = %q, want %q", test.dialect.driver, got, test.want)
+		}
+	}
+}
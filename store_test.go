@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// unreachableStore fails pings until failures run out.
+type unreachableStore struct {
+	Store
+	failures int
+	pings    int
+}
+
+func (s *unreachableStore) Ping(ctx context.Context) error {
+	s.pings++
+	if s.pings <= s.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestWaitForStore(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	s := &unreachableStore{failures: 3}
+	if err := waitForStore(s, time.Second, time.Millisecond, 2*time.Millisecond); err != nil || s.pings != 4 {
+		t.Errorf("waitForStore = %v after %d pings, want nil after 4", err, s.pings)
+	}
+
+	s = &unreachableStore{failures: 1000}
+	if err := waitForStore(s, 20*time.Millisecond, time.Millisecond, 5*time.Millisecond); err == nil {
+		t.Errorf("waitForStore of unreachable store = nil, want error after %d pings", s.pings)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+	// rows read at once when index is built
+	suggestBatch = 1000
+)
+
+// suggestFields are values of field parameter of /api/suggest.
+var suggestFields = []string{"title", "author", "client"}
+
+type Suggestion struct {
+	Value string
+	// number of books (or clients) having the value
+	Count int
+}
+
+type SuggestResponse struct {
+	Items []Suggestion
+}
+
+// suggestions is filled on start and updated by handlers changing books and clients.
+var suggestions = newSuggestIndex()
+
+// suggestIndex finds distinct values of book titles, authors and client names
+// having words starting with typed prefix.
+type suggestIndex struct {
+	mu     sync.RWMutex
+	fields map[string]*suggestField
+}
+
+// suggestField keeps values of one field: row id -> value, value -> number of
+// rows and sorted folded words of values.
+type suggestField struct {
+	rows   map[int]string
+	counts map[string]int
+	words  []suggestWord
+}
+
+type suggestWord struct {
+	Word  string
+	Value string
+}
+
+func (w suggestWord) less(other suggestWord) bool {
+	return w.Word < other.Word || w.Word == other.Word && w.Value < other.Value
+}
+
+func newSuggestIndex() *suggestIndex {
+	index := &suggestIndex{fields: make(map[string]*suggestField)}
+	for _, name := range suggestFields {
+		index.fields[name] = &suggestField{rows: make(map[int]string), counts: make(map[string]int)}
+	}
+	return index
+}
+
+func (x *suggestIndex) PutBook(book Book) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fields["title"].put(book.Id, book.Name)
+	x.fields["author"].put(book.Id, book.Author)
+}
+
+func (x *suggestIndex) RemoveBook(id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fields["title"].remove(id)
+	x.fields["author"].remove(id)
+}
+
+func (x *suggestIndex) PutClient(client Client) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fields["client"].put(client.Id, client.Name)
+}
+
+func (x *suggestIndex) RemoveClient(id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fields["client"].remove(id)
+}
+
+// Suggest returns at most limit values of field matching prefix, the most common first.
+// Every word of prefix must start a word of value, following the same order.
+func (x *suggestIndex) Suggest(field, prefix string, limit int) []Suggestion {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	words := tokenize(prefix)
+	if len(words) == 0 {
+		return nil
+	}
+	f := x.fields[field]
+	seen := make(map[string]bool)
+	var found []Suggestion
+	start := sort.Search(len(f.words), func(i int) bool { return !f.words[i].less(suggestWord{Word: words[0].Word}) })
+	for i := start; i < len(f.words) && strings.HasPrefix(f.words[i].Word, words[0].Word); i++ {
+		value := f.words[i].Value
+		if seen[value] || !matchesWords(value, words) {
+			continue
+		}
+		seen[value] = true
+		found = append(found, Suggestion{Value: value, Count: f.counts[value]})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Count != found[j].Count {
+			return found[i].Count > found[j].Count
+		}
+		return found[i].Value < found[j].Value
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// matchesWords tells if consecutive words of value start with words of prefix,
+// the last word of prefix may be typed partially.
+func matchesWords(value string, prefix []token) bool {
+	words := tokenize(value)
+	for i := 0; i+len(prefix) <= len(words); i++ {
+		matches := true
+		for j, p := range prefix {
+			if j == len(prefix)-1 {
+				matches = matches && strings.HasPrefix(words[i+j].Word, p.Word)
+			} else {
+				matches = matches && words[i+j].Word == p.Word
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *suggestField) put(id int, value string) {
+	f.remove(id)
+	if value == "" {
+		return
+	}
+	f.rows[id] = value
+	f.counts[value]++
+	if f.counts[value] > 1 {
+		return
+	}
+	for _, t := range tokenize(value) {
+		word := suggestWord{t.Word, value}
+		i := sort.Search(len(f.words), func(i int) bool { return !f.words[i].less(word) })
+		if i < len(f.words) && f.words[i] == word {
+			continue
+		}
+		f.words = append(f.words, suggestWord{})
+		copy(f.words[i+1:], f.words[i:])
+		f.words[i] = word
+	}
+}
+
+func (f *suggestField) remove(id int) {
+	value, ok := f.rows[id]
+	if !ok {
+		return
+	}
+	delete(f.rows, id)
+	f.counts[value]--
+	if f.counts[value] > 0 {
+		return
+	}
+	delete(f.counts, value)
+	for _, t := range tokenize(value) {
+		word := suggestWord{t.Word, value}
+		i := sort.Search(len(f.words), func(i int) bool { return !f.words[i].less(word) })
+		if i < len(f.words) && f.words[i] == word {
+			f.words = append(f.words[:i], f.words[i+1:]...)
+		}
+	}
+}
+
+// buildSuggestions reads all books and clients from store in batches.
+func buildSuggestions(ctx context.Context) error {
+	q := ListQuery{Sort: []Sort{{Field: "id"}}, Limit: suggestBatch}
+	for {
+		books, _, err := store.GetBooks(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, book := range books {
+			suggestions.PutBook(book)
+		}
+		if len(books) < suggestBatch {
+			break
+		}
+		q.After = []any{books[len(books)-1].Id}
+	}
+
+	q.After = nil
+	for {
+		clients, _, err := store.GetClients(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, client := range clients {
+			suggestions.PutClient(client)
+		}
+		if len(clients) < suggestBatch {
+			break
+		}
+		q.After = []any{clients[len(clients)-1].Id}
+	}
+	return nil
+}
+
+// GET /api/suggest?field=author&prefix=Sie
+func getSuggest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	field, prefix, limit := query.Get("field"), query.Get("prefix"), defaultSuggestLimit
+	var errs []FieldError
+	if _, ok := suggestions.fields[field]; !ok {
+		errs = append(errs, FieldError{Field: "field", Code: fieldInvalid, Message: "field must be one of " + strings.Join(suggestFields, ", ")})
+	}
+	if len(tokenize(prefix)) == 0 {
+		errs = append(errs, FieldError{Field: "prefix", Code: fieldRequired, Message: "prefix must have at least one letter or digit"})
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			errs = append(errs, FieldError{Field: "limit", Code: fieldInvalid, Message: "limit must be a number from 1 to " + strconv.Itoa(maxSuggestLimit)})
+		}
+		limit = n
+	}
+	if len(errs) > 0 {
+		writeProblem(w, r, problemInvalidParameter, strconv.Itoa(len(errs))+" invalid parameters", errs...)
+		slog.WarnContext(r.Context(), "GET /api/suggest wrong query "+r.URL.RawQuery)
+		return
+	}
+
+	response := SuggestResponse{Items: suggestions.Suggest(field, prefix, limit)}
+	if response.Items == nil {
+		response.Items = []Suggestion{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	errEncode := json.NewEncoder(w).Encode(response)
+	if errEncode != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "GET /api/suggest "+errEncode.Error())
+		return
+	}
+}
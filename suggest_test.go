@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSuggestIndex(t *testing.T) {
+	index := newSuggestIndex()
+	for _, book := range []Book{
+		{Id: 1, Name: "Solaris", Author: "Stanisław Lem"},
+		{Id: 2, Name: "The Cyberiad", Author: "Stanisław Lem"},
+		{Id: 3, Name: "Lem", Author: "Agnieszka Gajewska"},
+		{Id: 4, Name: "Saga", Author: "Stanisław Stasiak"},
+	} {
+		index.PutBook(book)
+	}
+	index.PutClient(Client{Id: 1, Name: "Jan Lem"})
+
+	tests := []struct {
+		field, prefix string
+		limit         int
+		want          []Suggestion
+	}{
+		// the most common first, ties by value
+		{"author", "sta", 10, []Suggestion{{"Stanisław Lem", 2}, {"Stanisław Stasiak", 1}}},
+		{"author", "stanislaw l", 10, []Suggestion{{"Stanisław Lem", 2}}},
+		{"author", "lem", 10, []Suggestion{{"Stanisław Lem", 2}}},
+		{"author", "lem stanislaw", 10, nil},
+		{"author", "STA", 1, []Suggestion{{"Stanisław Lem", 2}}},
+		{"title", "cyb", 10, []Suggestion{{"The Cyberiad", 1}}},
+		{"title", "s", 10, []Suggestion{{"Saga", 1}, {"Solaris", 1}}},
+		{"client", "lem", 10, []Suggestion{{"Jan Lem", 1}}},
+		{"title", "", 10, nil},
+	}
+	for _, tt := range tests {
+		if got := index.Suggest(tt.field, tt.prefix, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%s, %q, %d) = %v, want %v", tt.field, tt.prefix, tt.limit, got, tt.want)
+		}
+	}
+
+	index.PutBook(Book{Id: 1, Name: "Solaris", Author: "Lem"})
+	index.RemoveBook(2)
+	if got := index.Suggest("author", "stanislaw", 10); !reflect.DeepEqual(got, []Suggestion{{"Stanisław Stasiak", 1}}) {
+		t.Errorf("Suggest after update and remove = %v", got)
+	}
+	for name, field := range index.fields {
+		for _, word := range field.words {
+			if field.counts[word.Value] == 0 {
+				t.Errorf("%s: word %q of removed value %q left in index", name, word.Word, word.Value)
+			}
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h http.Handler) {
+		ctx := context.Background()
+		store.CreateBook(ctx, BookRequest{Name: "Solaris", Author: "Stanisław Lem"})
+		store.CreateClient(ctx, ClientRequest{Name: "Jan"})
+		if err := buildSuggestions(ctx); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, serve(h, "POST", "/api/books", `{"Name":"Eden","Author":"Stanisław Lem"}`), http.StatusCreated, "")
+
+		w := serve(h, "GET", "/api/suggest?field=author&prefix=stan", "")
+		expect(t, w, http.StatusOK, "")
+		if got := decodeBody[SuggestResponse](t, w); !reflect.DeepEqual(got.Items, []Suggestion{{"Stanisław Lem", 2}}) {
+			t.Errorf("GET /api/suggest?field=author&prefix=stan = %+v", got)
+		}
+		w = serve(h, "GET", "/api/suggest?field=client&prefix=x", "")
+		expect(t, w, http.StatusOK, "")
+		if got := decodeBody[SuggestResponse](t, w); got.Items == nil || len(got.Items) != 0 {
+			t.Errorf("GET /api/suggest?field=client&prefix=x = %+v, want no items", got)
+		}
+		expect(t, serve(h, "GET", "/api/suggest?field=isbn&prefix=1", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+		expect(t, serve(h, "GET", "/api/suggest?field=title&prefix=%20&limit=100", ""), http.StatusBadRequest, problemInvalidParameter.Code)
+	})
+}
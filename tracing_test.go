@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceRequests(t *testing.T) {
+	h := newTestHandler(t, "sqlite")
+	if _, err := store.CreateBook(context.Background(), BookRequest{Name: "Solaris", Author: "Lem"}); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	defer func(previous trace.Tracer) { tracer = previous }(tracer)
+	tracer = provider.Tracer("library")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	w := serve(h, "GET", "/api/books/1", "", "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	expect(t, w, http.StatusOK, "")
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("recorded %d spans, want query and request", len(ended))
+	}
+	query, request := ended[0], ended[1]
+	// request span continues trace of the caller, query span is its child
+	if request.Name() != "GET /api/books/{id}" || request.SpanContext().TraceID().String() != traceID || request.SpanKind() != trace.SpanKindServer {
+		t.Errorf("request span %s in trace %s", request.Name(), request.SpanContext().TraceID())
+	}
+	if query.Name() != "SELECT" || query.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("query span %s with parent %s", query.Name(), query.Parent().SpanID())
+	}
+}